@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+)
+
+const (
+	colorReset  = "\x1b[0m"
+	colorKey    = "\x1b[34;1m"
+	colorString = "\x1b[32m"
+	colorNumber = "\x1b[36m"
+	colorBool   = "\x1b[33m"
+	colorNull   = "\x1b[1;30m"
+)
+
+// colorizeJSON adds ANSI color escape sequences to the JSON (indented or not) like jq does.
+func colorizeJSON(b []byte) []byte {
+	var out bytes.Buffer
+	for i := 0; i < len(b); {
+		c := b[i]
+		switch {
+		case c == '"':
+			j := i + 1
+			for ; j < len(b); j++ {
+				if b[j] == '\\' {
+					j++
+					continue
+				}
+				if b[j] == '"' {
+					break
+				}
+			}
+			if j >= len(b) {
+				j = len(b) - 1
+			}
+			str := b[i : j+1]
+			// a string followed by a colon is an object key
+			k := j + 1
+			for k < len(b) && (b[k] == ' ' || b[k] == '\n' || b[k] == '\t' || b[k] == '\r') {
+				k++
+			}
+			if k < len(b) && b[k] == ':' {
+				writeColored(&out, colorKey, str)
+			} else {
+				writeColored(&out, colorString, str)
+			}
+			i = j + 1
+		case c == '-' || (c >= '0' && c <= '9'):
+			j := i
+			for j < len(b) && bytes.IndexByte([]byte("+-.eE0123456789"), b[j]) >= 0 {
+				j++
+			}
+			writeColored(&out, colorNumber, b[i:j])
+			i = j
+		case bytes.HasPrefix(b[i:], []byte("true")):
+			writeColored(&out, colorBool, b[i:i+4])
+			i += 4
+		case bytes.HasPrefix(b[i:], []byte("false")):
+			writeColored(&out, colorBool, b[i:i+5])
+			i += 5
+		case bytes.HasPrefix(b[i:], []byte("null")):
+			writeColored(&out, colorNull, b[i:i+4])
+			i += 4
+		default:
+			out.WriteByte(c)
+			i++
+		}
+	}
+	return out.Bytes()
+}
+
+func writeColored(out *bytes.Buffer, color string, b []byte) {
+	out.WriteString(color)
+	out.Write(b)
+	out.WriteString(colorReset)
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+)
+
+// completion scripts complete flags, and resource addresses by running tfstate-lookup itself (List) against the -state in the command line.
+var completionTemplates = map[string]string{
+	"bash": `# bash completion for tfstate-lookup
+_tfstate_lookup() {
+    local cur prev state i
+    cur="${COMP_WORDS[COMP_CWORD]}"
+    prev="${COMP_WORDS[COMP_CWORD-1]}"
+    case "$prev" in
+        -s|-state)
+            COMPREPLY=($(compgen -f -- "$cur"))
+            return
+            ;;
+        -output)
+            COMPREPLY=($(compgen -W "json yaml" -- "$cur"))
+            return
+            ;;
+        -completion)
+            COMPREPLY=($(compgen -W "bash zsh fish" -- "$cur"))
+            return
+            ;;
+        {{ .ValueFlags | join "|" }})
+            return
+            ;;
+    esac
+    if [[ "$cur" == -* ]]; then
+        COMPREPLY=($(compgen -W "{{ .Flags | join " " }}" -- "$cur"))
+        return
+    fi
+    for ((i = 1; i < COMP_CWORD; i++)); do
+        case "${COMP_WORDS[i]}" in
+            -s|-state) state="${COMP_WORDS[i+1]}" ;;
+        esac
+    done
+    COMPREPLY=($(compgen -W "$(tfstate-lookup ${state:+-state "$state"} 2>/dev/null)" -- "$cur"))
+}
+complete -F _tfstate_lookup tfstate-lookup
+`,
+	"zsh": `#compdef tfstate-lookup
+# zsh completion for tfstate-lookup
+_tfstate_lookup() {
+    local -a state addrs
+    local i
+    case "${words[CURRENT-1]}" in
+        -s|-state)
+            _files
+            return
+            ;;
+        -output)
+            compadd -- json yaml
+            return
+            ;;
+        -completion)
+            compadd -- bash zsh fish
+            return
+            ;;
+        {{ .ValueFlags | join "|" }})
+            return
+            ;;
+    esac
+    if [[ "$PREFIX" == -* ]]; then
+        compadd -- {{ .Flags | join " " }}
+        return
+    fi
+    for ((i = 2; i < CURRENT; i++)); do
+        case "${words[i]}" in
+            -s|-state) state=(-state "${words[i+1]}") ;;
+        esac
+    done
+    addrs=(${(f)"$(tfstate-lookup $state 2>/dev/null)"})
+    compadd -- $addrs
+}
+compdef _tfstate_lookup tfstate-lookup
+`,
+	"fish": `# fish completion for tfstate-lookup
+function __tfstate_lookup_addresses
+    set -l tokens (commandline -opc)
+    set -l state
+    for i in (seq (count $tokens))
+        if contains -- $tokens[$i] -s -state
+            set state -state $tokens[(math $i + 1)]
+        end
+    end
+    tfstate-lookup $state 2>/dev/null
+end
+complete -c tfstate-lookup -f -a '(__tfstate_lookup_addresses)'
+{{- range .Options }}
+complete -c tfstate-lookup -o {{ .Name }}{{ if .Value }} -r{{ end }} -d {{ .Usage | quote }}
+{{- end }}
+complete -c tfstate-lookup -o s -o state -r -F
+complete -c tfstate-lookup -o output -x -a 'json yaml'
+complete -c tfstate-lookup -o completion -x -a 'bash zsh fish'
+`,
+}
+
+type completionOption struct {
+	Name  string
+	Usage string
+	Value bool
+}
+
+func printCompletion(w io.Writer, shell string) error {
+	src, ok := completionTemplates[shell]
+	if !ok {
+		return fmt.Errorf("unsupported shell for completion: %s", shell)
+	}
+	tmpl, err := template.New(shell).Funcs(template.FuncMap{
+		"join": func(sep string, s []string) string {
+			return strings.Join(s, sep)
+		},
+		"quote": func(s string) string {
+			return "'" + strings.ReplaceAll(s, "'", `\'`) + "'"
+		},
+	}).Parse(src)
+	if err != nil {
+		return err
+	}
+	var data struct {
+		Options    []completionOption
+		Flags      []string
+		ValueFlags []string
+	}
+	flag.VisitAll(func(f *flag.Flag) {
+		opt := completionOption{Name: f.Name, Usage: f.Usage, Value: true}
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+			opt.Value = false
+		}
+		data.Options = append(data.Options, opt)
+		data.Flags = append(data.Flags, "-"+f.Name)
+		switch f.Name {
+		case "s", "state", "output", "completion":
+			// completed individually
+		default:
+			if opt.Value {
+				data.ValueFlags = append(data.ValueFlags, "-"+f.Name)
+			}
+		}
+	})
+	return tmpl.Execute(w, data)
+}
@@ -1,38 +1,105 @@
 package main
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"net/url"
 	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"runtime/debug"
 	"strings"
 	"time"
 
 	"github.com/fujiwara/tfstate-lookup/tfstate"
 	"github.com/manifoldco/promptui"
-	"github.com/mattn/go-isatty"
 )
 
+// Version is set by ldflags at build time
+var Version = "current"
+
 var DefaultStateFiles = []string{
 	"terraform.tfstate",
 	".terraform/terraform.tfstate",
 }
 
+// exitCodeNotFound is an exit code when the key is not found in the state
+const exitCodeNotFound = 3
+
+type notFoundError struct {
+	keys   []string
+	causes []*tfstate.NotFoundError // suggestions are computed only when the error is printed
+}
+
+func (e *notFoundError) Error() string {
+	msg := fmt.Sprintf("%s is not found in the state", strings.Join(e.keys, ", "))
+	var suggestions []string
+	for _, c := range e.causes {
+		if s := c.Suggestion(); s != "" {
+			suggestions = append(suggestions, s)
+		}
+	}
+	if len(suggestions) > 0 {
+		msg += fmt.Sprintf(", did you mean %s?", strings.Join(suggestions, ", "))
+	}
+	return msg
+}
+
 func main() {
 	if err := _main(); err != nil {
 		fmt.Fprintln(os.Stderr, err.Error())
+		var nf *notFoundError
+		if errors.As(err, &nf) || errors.Is(err, tfstate.ErrNotFound) {
+			os.Exit(exitCodeNotFound)
+		}
 		os.Exit(1)
 	}
 }
 
+// cli holds the options and runs the command against the state
+type cli struct {
+	args           []string
+	interactive    bool
+	outputOpt      outputOption
+	lookupOpt      lookupOption
+	resourceTypes  []string
+	fullAddress    bool
+	flatten        bool
+	paths          bool
+	keys           bool
+	sensitivePaths bool
+	match          func(string) bool
+	replMode       bool
+	listModules    bool
+	showOutputs    bool
+	showMeta       bool
+	listWorkspaces bool
+	table          bool
+	diff           *tfstate.TFState // the state to compare with -diff
+	outFile        string           // -o
+}
+
 func _main() error {
 	var (
-		stateLoc         string
+		c                cli
+		stateLocs        stateFlag
 		defaultStateFile = DefaultStateFiles[0]
-		interactive      bool
 		timeout          time.Duration
+		showVersion      bool
+		workspace        string
+		completion       string
+		format           string
+		resourceTypes    string
+		grep             string
+		grepRegex        string
+		watch            bool
+		diffLoc          string
+		outFile          string
+		provider         string
+		readOpt          readOption
 	)
 	for _, name := range DefaultStateFiles {
 		if _, err := os.Stat(name); err == nil {
@@ -40,30 +107,285 @@ func _main() error {
 			break
 		}
 	}
+	for _, env := range []string{"TFSTATE", "TF_STATE"} {
+		if v := os.Getenv(env); v != "" {
+			defaultStateFile = v
+			break
+		}
+	}
 
-	flag.StringVar(&stateLoc, "state", defaultStateFile, "tfstate file path or URL")
-	flag.StringVar(&stateLoc, "s", defaultStateFile, "tfstate file path or URL")
-	flag.BoolVar(&interactive, "i", false, "interactive mode")
-	flag.DurationVar(&timeout, "timeout", 0, "timeout for reading tfstate")
+	stateLocs.locs = []string{defaultStateFile}
+	flag.Var(&stateLocs, "state", "tfstate file `path` or URL (- for stdin, repeatable to merge states)")
+	flag.Var(&stateLocs, "s", "tfstate file `path` or URL (- for stdin, repeatable to merge states)")
+	flag.BoolVar(&c.interactive, "i", false, "interactive mode")
+	flag.DurationVar(&timeout, "timeout", 30*time.Second, "timeout for reading tfstate (0 means no timeout)")
+	flag.StringVar(&c.outputOpt.format, "output", "json", "output format (json, yaml)")
+	flag.BoolVar(&c.outputOpt.raw, "raw", false, "output a string, number or bool as is, others as compact JSON")
+	flag.StringVar(&c.lookupOpt.query, "query", "", "jq query for the whole tfstate, or for the looked up object with a key")
+	flag.StringVar(&c.lookupOpt.query, "q", "", "jq query for the whole tfstate, or for the looked up object with a key")
+	flag.BoolVar(&showVersion, "version", false, "show version")
+	flag.StringVar(&workspace, "workspace", "", "workspace name (default: TF_WORKSPACE or environment file)")
+	flag.StringVar(&workspace, "w", "", "workspace name (default: TF_WORKSPACE or environment file)")
+	flag.BoolVar(&readOpt.strictWorkspace, "strict-workspace", false, "an error on a missing environment file of a local state without -workspace")
+	flag.StringVar(&format, "format", "", "Go text/template to render the looked up object (e.g. '{{ .id }}')")
+	flag.BoolVar(&c.outputOpt.compact, "compact", false, "output compact JSON even to a TTY")
+	flag.BoolVar(&c.outputOpt.pretty, "pretty", false, "output indented JSON even to a file or a pipe")
+	flag.StringVar(&c.outputOpt.color, "color", "auto", "colorize JSON output (always, never, auto)")
+	flag.BoolVar(&c.outputOpt.strict, "strict", false, "an error on missing keys in -format template")
+	flag.StringVar(&resourceTypes, "type", "", "list only resources of the types (comma separated)")
+	flag.StringVar(&provider, "provider", "", "use only resources of the provider (e.g. an alias us_east_1 or hashicorp/aws)")
+	flag.BoolVar(&c.fullAddress, "full", false, "list resource addresses as terraform state list does")
+	flag.BoolVar(&c.flatten, "flatten", false, "print all attributes (or attributes of the keys) as flattened key=value lines")
+	flag.BoolVar(&c.paths, "paths", false, "print paths of all attributes of the keys")
+	flag.BoolVar(&c.keys, "keys", false, "print sorted attribute names of the keys")
+	flag.BoolVar(&c.sensitivePaths, "sensitive-paths", false, "print keys of sensitive attributes of the keys (or of all resources and outputs)")
+	flag.StringVar(&grep, "grep", "", "search attributes whose values contain the string")
+	flag.StringVar(&grepRegex, "regex", "", "search attributes whose values match the regular expression")
+	flag.BoolVar(&c.replMode, "repl", false, "read keys or jq queries from stdin line by line and print the results")
+	flag.BoolVar(&c.listModules, "modules", false, "list module paths")
+	flag.BoolVar(&c.showOutputs, "outputs", false, "print outputs of the root module as terraform output -json does")
+	flag.BoolVar(&c.showMeta, "meta", false, "print version, terraform_version, serial and lineage of the state")
+	flag.BoolVar(&c.listWorkspaces, "list-workspaces", false, "list workspaces in the backend of the state (s3, gcs)")
+	flag.BoolVar(&c.lookupOpt.maskSensitive, "mask-sensitive", false, "replace sensitive values with "+sensitiveMask)
+	flag.BoolVar(&c.lookupOpt.nullOnMissing, "null-on-missing", false, "print null and exit 0 when the key is not found")
+	flag.BoolVar(&c.table, "table", false, "list resources as a table (module, type, name, provider and number of instances)")
+	flag.StringVar(&diffLoc, "diff", "", "print changed attributes from the state to the other state `path` or URL")
+	flag.BoolVar(&watch, "watch", false, "re-evaluate the keys or query whenever the state file is changed")
+	flag.StringVar(&outFile, "o", "", "write the output to the `file` instead of stdout")
+	flag.StringVar(&completion, "completion", "", "print a completion script for the shell (bash, zsh, fish)")
 	flag.Parse()
+	c.args = flag.Args()
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "output" {
+			c.outputOpt.formatSpecified = true
+		}
+	})
+
+	if showVersion {
+		fmt.Println(versionString())
+		return nil
+	}
+	if completion != "" {
+		return printCompletion(os.Stdout, completion)
+	}
+	readOpt.workspace = workspace
+	if workspace == "" {
+		readOpt.workspace = os.Getenv("TF_WORKSPACE")
+		readOpt.workspaceFromEnv = readOpt.workspace != ""
+	}
+	if resourceTypes != "" {
+		c.resourceTypes = strings.Split(resourceTypes, ",")
+	}
+
+	switch c.outputOpt.format {
+	case "json", "yaml":
+	default:
+		return fmt.Errorf("unsupported output format: %s", c.outputOpt.format)
+	}
+	if c.outputOpt.compact && c.outputOpt.pretty {
+		return errors.New("-compact and -pretty are mutually exclusive")
+	}
+	switch c.outputOpt.color {
+	case "always", "never", "auto":
+	default:
+		return fmt.Errorf("unsupported color mode: %s", c.outputOpt.color)
+	}
+	switch {
+	case grep != "" && grepRegex != "":
+		return errors.New("-grep and -regex are mutually exclusive")
+	case grep != "":
+		c.match = func(v string) bool { return strings.Contains(v, grep) }
+	case grepRegex != "":
+		re, err := regexp.Compile(grepRegex)
+		if err != nil {
+			return fmt.Errorf("invalid -regex: %w", err)
+		}
+		c.match = re.MatchString
+	}
+	if format != "" {
+		tmpl, err := parseTemplate(format)
+		if err != nil {
+			return fmt.Errorf("invalid -format template: %w", err)
+		}
+		c.outputOpt.template = tmpl
+	}
 
-	var ctx = context.Background()
-	var cancel context.CancelFunc
-	if timeout > 0 {
-		ctx, cancel = context.WithTimeout(ctx, timeout)
-		defer cancel()
+	if c.replMode {
+		for _, loc := range stateLocs.locs {
+			if loc == "-" {
+				return errors.New("-repl can't read the state from stdin")
+			}
+		}
 	}
 
-	state, err := tfstate.ReadURL(ctx, stateLoc)
+	c.outFile = outFile
+
+	read := func(ctx context.Context, locs []string) (*tfstate.TFState, error) {
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		state, err := readStates(ctx, locs, readOpt)
+		if err != nil || provider == "" {
+			return state, err
+		}
+		return state.FilterProvider(provider)
+	}
+	if diffLoc != "" {
+		before, err := read(context.Background(), stateLocs.locs)
+		if err != nil {
+			return err
+		}
+		// the other state is read with the same options
+		after, err := read(context.Background(), []string{diffLoc})
+		if err != nil {
+			return err
+		}
+		c.diff = before
+		return c.run(after)
+	}
+	if watch {
+		return c.watch(stateLocs.locs, read)
+	}
+	state, err := read(context.Background(), stateLocs.locs)
 	if err != nil {
 		return err
 	}
-	if len(flag.Args()) == 0 {
-		names, err := state.List()
+	return c.run(state)
+}
+
+// run runs the command against the state.
+// With -o, the output is written to a temporary file which replaces the file after a successful run,
+// so a failed run doesn't truncate the file and each run of -watch rewrites it.
+func (c *cli) run(state *tfstate.TFState) error {
+	if c.outFile == "" {
+		return c.runState(state)
+	}
+	mode := os.FileMode(0644)
+	if st, err := os.Stat(c.outFile); err == nil {
+		mode = st.Mode().Perm()
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.outFile), "."+filepath.Base(c.outFile)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op after rename
+	c.outputOpt.out = tmp
+	defer func() { c.outputOpt.out = nil }()
+	runErr := c.runState(state)
+	if runErr != nil {
+		// keep the file as is, unless multiple keys with missing ones are printed
+		var nf *notFoundError
+		st, err := tmp.Stat()
+		if !errors.As(runErr, &nf) || err != nil || st.Size() == 0 {
+			tmp.Close()
+			return runErr
+		}
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), c.outFile); err != nil {
+		return err
+	}
+	return runErr
+}
+
+func (c *cli) runState(state *tfstate.TFState) error {
+	if c.diff != nil {
+		var changes []tfstate.Change
+		var err error
+		if c.lookupOpt.maskSensitive {
+			changes, err = tfstate.DiffMasked(c.diff, state, sensitiveMask)
+		} else {
+			changes, err = tfstate.Diff(c.diff, state)
+		}
+		if err != nil {
+			return err
+		}
+		return printChanges(changes, c.outputOpt)
+	}
+	if c.replMode {
+		return runREPL(state, c.lookupOpt, c.outputOpt)
+	}
+	if c.showOutputs {
+		outputs, err := state.Outputs()
+		if err != nil {
+			return err
+		}
+		if c.lookupOpt.maskSensitive {
+			for name, o := range outputs {
+				if o.Sensitive {
+					o.Value = sensitiveMask
+					outputs[name] = o
+				}
+			}
+		}
+		return printObject(&tfstate.Object{Value: outputs}, c.outputOpt)
+	}
+	if c.showMeta {
+		return printObject(&tfstate.Object{Value: map[string]interface{}{
+			"version":           state.FormatVersion(),
+			"terraform_version": state.TerraformVersion(),
+			"serial":            state.Serial(),
+			"lineage":           state.Lineage(),
+		}}, c.outputOpt)
+	}
+	if c.listWorkspaces {
+		workspaces, err := state.Workspaces()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(c.outputOpt.writer(), strings.Join(workspaces, "\n"))
+		return nil
+	}
+	if c.keys {
+		return printKeys(state, c.args, c.lookupOpt, c.outputOpt)
+	}
+	if c.sensitivePaths {
+		return printSensitivePaths(state, c.args, c.outputOpt)
+	}
+	if c.paths {
+		return printPaths(state, c.args, c.outputOpt)
+	}
+	if c.flatten || c.match != nil {
+		return printFlatten(state, c.args, c.match, c.lookupOpt, c.outputOpt)
+	}
+	if len(c.args) == 0 && c.lookupOpt.query != "" {
+		if c.lookupOpt.maskSensitive {
+			return errors.New("-mask-sensitive is not supported for a query of the whole state")
+		}
+		obj, err := state.Query(c.lookupOpt.query)
+		if err != nil {
+			return err
+		}
+		return printObject(obj, c.outputOpt)
+	}
+	if len(c.args) == 0 {
+		if c.table {
+			return printTable(state, c.resourceTypes, c.outputOpt)
+		}
+		var names []string
+		var err error
+		switch {
+		case c.listModules:
+			names, err = state.ListModules()
+		case c.fullAddress:
+			names, err = state.ListAddresses(c.resourceTypes...)
+		case len(c.resourceTypes) > 0:
+			names, err = state.ListByType(c.resourceTypes...)
+		default:
+			names, err = state.List()
+		}
 		if err != nil {
 			return err
 		}
-		if interactive {
+		if c.interactive {
 			selected, err := promptForSelection(names)
 			if err != nil {
 				return err
@@ -72,31 +394,173 @@ func _main() error {
 			if err != nil {
 				return err
 			}
-			printObject(obj)
+			return printObject(obj, c.outputOpt)
 		} else {
-			fmt.Println(strings.Join(names, "\n"))
+			fmt.Fprintln(c.outputOpt.writer(), strings.Join(names, "\n"))
 		}
-	} else {
-		obj, err := state.Lookup(flag.Arg(0))
+	} else if len(c.args) == 1 {
+		obj, err := lookup(state, c.args[0], c.lookupOpt)
 		if err != nil {
 			return err
 		}
-		printObject(obj)
+		return printObject(obj, c.outputOpt)
+	} else {
+		// multiple keys are printed as an object keyed by the address
+		values := make(map[string]interface{}, len(c.args))
+		var missing []string
+		var causes []*tfstate.NotFoundError
+		for _, key := range c.args {
+			obj, err := lookup(state, key, c.lookupOpt)
+			var nf *notFoundError
+			if errors.As(err, &nf) {
+				missing = append(missing, key)
+				causes = append(causes, nf.causes...)
+				values[key] = nil
+				continue
+			} else if err != nil {
+				return err
+			}
+			values[key] = obj.Value
+		}
+		if err := printObject(&tfstate.Object{Value: values}, c.outputOpt); err != nil {
+			return err
+		}
+		if len(missing) > 0 {
+			return &notFoundError{keys: missing, causes: causes}
+		}
 	}
 	return nil
 }
 
-func printObject(obj *tfstate.Object) {
-	b := obj.Bytes()
-	w := os.Stdout
-	if isatty.IsTerminal(w.Fd()) && (bytes.HasPrefix(b, []byte("[")) || bytes.HasPrefix(b, []byte("{"))) {
-		var out bytes.Buffer
-		json.Indent(&out, b, "", "  ")
-		out.WriteRune('\n')
-		out.WriteTo(w)
-	} else {
-		fmt.Fprintln(w, string(b))
+func versionString() string {
+	version, gojqVersion := Version, "unknown"
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if version == "current" && info.Main.Version != "" && info.Main.Version != "(devel)" {
+			// installed by go install
+			version = info.Main.Version
+		}
+		for _, dep := range info.Deps {
+			if dep.Path == "github.com/itchyny/gojq" {
+				gojqVersion = dep.Version
+			}
+		}
+	}
+	return fmt.Sprintf("tfstate-lookup %s (%s, gojq %s)", version, runtime.Version(), gojqVersion)
+}
+
+// stateFlag is a repeatable flag for -state. The default value is replaced by the first one.
+type stateFlag struct {
+	locs []string
+	set  bool
+}
+
+func (f *stateFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return strings.Join(f.locs, ", ")
+}
+
+func (f *stateFlag) Set(v string) error {
+	if !f.set {
+		f.locs = nil
+		f.set = true
+	}
+	f.locs = append(f.locs, v)
+	return nil
+}
+
+// readOption holds the options to read states
+type readOption struct {
+	workspace        string
+	workspaceFromEnv bool // TF_WORKSPACE is ignored for URLs without workspaces
+	strictWorkspace  bool
+}
+
+func readStates(ctx context.Context, locs []string, opt readOption) (*tfstate.TFState, error) {
+	if len(locs) == 1 {
+		return readState(ctx, locs[0], opt)
+	}
+	states := make([]*tfstate.TFState, 0, len(locs))
+	for _, loc := range locs {
+		state, err := readState(ctx, loc, opt)
+		if err != nil {
+			return nil, err
+		}
+		states = append(states, state)
+	}
+	merged, conflicts, err := tfstate.Merge(states...)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range conflicts {
+		fmt.Fprintf(os.Stderr, "warning: %s exists in multiple states, the first one is used\n", name)
+	}
+	return merged, nil
+}
+
+func readState(ctx context.Context, loc string, opt readOption) (*tfstate.TFState, error) {
+	if loc == "-" {
+		// the workspace can't be detected from stdin
+		return tfstate.ReadWithWorkspace(ctx, os.Stdin, opt.workspace)
+	}
+	if u, err := url.Parse(loc); opt.strictWorkspace && err == nil && u.Scheme == "" {
+		return tfstate.ReadFileWithOptions(loc,
+			tfstate.WithContext(ctx),
+			tfstate.WithWorkspace(opt.workspace),
+			tfstate.WithStrictWorkspace(true),
+		)
+	}
+	state, err := tfstate.ReadURLWithWorkspace(ctx, loc, opt.workspace)
+	if errors.Is(err, tfstate.ErrWorkspaceNotSupported) && opt.workspaceFromEnv {
+		return tfstate.ReadURLWithWorkspace(ctx, loc, "")
+	}
+	return state, err
+}
+
+type lookupOption struct {
+	query         string
+	maskSensitive bool
+	nullOnMissing bool
+}
+
+// sensitiveMask replaces sensitive values with -mask-sensitive
+const sensitiveMask = "***"
+
+func (opt lookupOption) lookupState(state *tfstate.TFState, key string) (*tfstate.Object, error) {
+	if opt.maskSensitive {
+		return state.LookupMasked(key, sensitiveMask)
+	}
+	return state.Lookup(key)
+}
+
+func lookup(state *tfstate.TFState, key string, opt lookupOption) (*tfstate.Object, error) {
+	obj, err := opt.lookupState(state, key)
+	if errors.Is(err, tfstate.ErrNotFound) {
+		if opt.nullOnMissing {
+			return &tfstate.Object{}, nil
+		}
+		nf := &notFoundError{keys: []string{key}}
+		var lookupErr *tfstate.NotFoundError
+		if errors.As(err, &lookupErr) {
+			nf.causes = []*tfstate.NotFoundError{lookupErr}
+		}
+		return nil, nf
+	} else if err != nil {
+		return nil, err
+	}
+	if opt.query != "" {
+		res, err := obj.Query(opt.query)
+		if errors.Is(err, tfstate.ErrNotFound) {
+			// no results of the query
+			if opt.nullOnMissing {
+				return &tfstate.Object{}, nil
+			}
+			return nil, &notFoundError{keys: []string{key}}
+		}
+		return res, err
 	}
+	return obj, nil
 }
 
 func promptForSelection(choices []string) (string, error) {
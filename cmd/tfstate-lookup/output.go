@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/fujiwara/tfstate-lookup/tfstate"
+	"github.com/mattn/go-isatty"
+)
+
+type outputOption struct {
+	format          string
+	formatSpecified bool
+	raw             bool
+	template        *template.Template
+	strict          bool
+	color           string
+	compact         bool
+	pretty          bool
+	out             *os.File
+}
+
+// writer returns the file to write the output (-o), or stdout.
+func (opt outputOption) writer() *os.File {
+	if opt.out != nil {
+		return opt.out
+	}
+	return os.Stdout
+}
+
+// useColor reports whether the output to the TTY (or not) should be colorized.
+func (opt outputOption) useColor(tty bool) bool {
+	switch opt.color {
+	case "always":
+		return true
+	case "never":
+		return false
+	default:
+		// auto
+		return tty && os.Getenv("NO_COLOR") == ""
+	}
+}
+
+func printObject(obj *tfstate.Object, opt outputOption) error {
+	w := opt.writer()
+	if opt.template != nil {
+		return printTemplate(obj, opt)
+	}
+	if opt.raw {
+		// like jq -r
+		fmt.Fprintln(w, obj.String())
+		return nil
+	}
+	if opt.format == "yaml" {
+		b, err := obj.YAML()
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(b)
+		return err
+	}
+	b := obj.Bytes()
+	tty := isatty.IsTerminal(w.Fd())
+	isJSON := bytes.HasPrefix(b, []byte("[")) || bytes.HasPrefix(b, []byte("{"))
+	if isJSON && (tty || opt.pretty) && !opt.compact {
+		var out bytes.Buffer
+		json.Indent(&out, b, "", "  ")
+		b = out.Bytes()
+	}
+	if isJSON && opt.useColor(tty) {
+		b = colorizeJSON(b)
+	}
+	fmt.Fprintln(w, string(b))
+	return nil
+}
+
+// templateFuncs are functions available in -format template.
+var templateFuncs = template.FuncMap{
+	// default renders nil and missing values as "" instead of "<no value>"
+	"default": func(v interface{}) interface{} {
+		if v == nil {
+			return ""
+		}
+		return v
+	},
+}
+
+// parseTemplate parses -format template. Values of actions are piped to default,
+// so that missing keys in map[string]interface{} are rendered as "".
+func parseTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("format").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			pipeDefault(t.Tree, t.Tree.Root)
+		}
+	}
+	return tmpl, nil
+}
+
+func pipeDefault(tree *parse.Tree, node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Nodes {
+			pipeDefault(tree, c)
+		}
+	case *parse.ActionNode:
+		if len(n.Pipe.Decl) == 0 {
+			ident := parse.NewIdentifier("default").SetTree(tree).SetPos(n.Pos)
+			n.Pipe.Cmds = append(n.Pipe.Cmds, &parse.CommandNode{NodeType: parse.NodeCommand, Pos: n.Pos, Args: []parse.Node{ident}})
+		}
+	case *parse.IfNode:
+		pipeDefault(tree, n.List)
+		pipeDefault(tree, n.ElseList)
+	case *parse.RangeNode:
+		pipeDefault(tree, n.List)
+		pipeDefault(tree, n.ElseList)
+	case *parse.WithNode:
+		pipeDefault(tree, n.List)
+		pipeDefault(tree, n.ElseList)
+	}
+}
+
+func printTemplate(obj *tfstate.Object, opt outputOption) error {
+	tmpl := opt.template
+	if opt.strict {
+		tmpl = tmpl.Option("missingkey=error")
+	}
+	var out strings.Builder
+	if err := tmpl.Execute(&out, obj.Value); err != nil {
+		return err
+	}
+	s := out.String()
+	if !strings.HasSuffix(s, "\n") {
+		s += "\n"
+	}
+	_, err := opt.writer().WriteString(s)
+	return err
+}
+
+// printFlatten prints flattened key=value lines. If match is not nil, only the lines of matched values are printed.
+func printFlatten(state *tfstate.TFState, keys []string, match func(string) bool, opt lookupOption, outOpt outputOption) error {
+	var m map[string]string
+	var err error
+	switch {
+	case len(keys) == 0 && !opt.maskSensitive:
+		if m, err = state.Flatten(); err != nil {
+			return err
+		}
+	case len(keys) == 0:
+		names, err := state.List()
+		if err != nil {
+			return err
+		}
+		m = make(map[string]string)
+		for _, name := range names {
+			obj, err := opt.lookupState(state, name)
+			if err != nil {
+				return err
+			}
+			for k, v := range obj.Flatten(name) {
+				m[k] = v
+			}
+		}
+	default:
+		m = make(map[string]string)
+		for _, key := range keys {
+			obj, err := lookup(state, key, lookupOption{maskSensitive: opt.maskSensitive})
+			if err != nil {
+				return err
+			}
+			for k, v := range obj.Flatten(key) {
+				m[k] = v
+			}
+		}
+	}
+	lines := make([]string, 0, len(m))
+	for k, v := range m {
+		if match != nil && !match(v) {
+			continue
+		}
+		lines = append(lines, k+"="+v)
+	}
+	sort.Strings(lines)
+	w := bufio.NewWriter(outOpt.writer())
+	for _, line := range lines {
+		fmt.Fprintln(w, line)
+	}
+	return w.Flush()
+}
+
+// printPaths prints the paths of all attributes of the keys, which can be looked up as they are.
+func printPaths(state *tfstate.TFState, keys []string, outOpt outputOption) error {
+	if len(keys) == 0 {
+		return errors.New("-paths requires keys")
+	}
+	w := bufio.NewWriter(outOpt.writer())
+	for _, key := range keys {
+		obj, err := lookup(state, key, lookupOption{})
+		if err != nil {
+			return err
+		}
+		for _, p := range obj.Paths() {
+			fmt.Fprintln(w, key+p)
+		}
+	}
+	return w.Flush()
+}
+
+// printKeys prints the attribute names of the keys.
+func printKeys(state *tfstate.TFState, keys []string, opt lookupOption, outOpt outputOption) error {
+	if len(keys) == 0 {
+		return errors.New("-keys requires keys")
+	}
+	w := bufio.NewWriter(outOpt.writer())
+	for _, key := range keys {
+		obj, err := lookup(state, key, opt)
+		if err != nil {
+			return err
+		}
+		names, err := obj.Keys()
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			fmt.Fprintln(w, name)
+		}
+	}
+	return w.Flush()
+}
+
+// printSensitivePaths prints the keys of sensitive attributes of the keys, or of all resources and outputs without keys.
+func printSensitivePaths(state *tfstate.TFState, keys []string, outOpt outputOption) error {
+	if len(keys) == 0 {
+		names, err := state.List()
+		if err != nil {
+			return err
+		}
+		keys = names
+	}
+	w := bufio.NewWriter(outOpt.writer())
+	for _, key := range keys {
+		paths, err := state.SensitiveAttributes(key)
+		if err != nil {
+			return err
+		}
+		for _, p := range paths {
+			if p == "." {
+				fmt.Fprintln(w, key)
+			} else {
+				fmt.Fprintln(w, key+p)
+			}
+		}
+	}
+	return w.Flush()
+}
+
+// printChanges prints the changes as text lines, or as JSON (YAML) with -output explicitly.
+func printChanges(changes []tfstate.Change, opt outputOption) error {
+	if opt.formatSpecified {
+		return printObject(&tfstate.Object{Value: changes}, opt)
+	}
+	w := bufio.NewWriter(opt.writer())
+	for _, c := range changes {
+		switch c.Action {
+		case tfstate.ChangeAdded:
+			fmt.Fprintf(w, "+ %s=%s\n", c.Key, c.After)
+		case tfstate.ChangeRemoved:
+			fmt.Fprintf(w, "- %s=%s\n", c.Key, c.Before)
+		case tfstate.ChangeChanged:
+			fmt.Fprintf(w, "~ %s=%s => %s\n", c.Key, c.Before, c.After)
+		}
+	}
+	return w.Flush()
+}
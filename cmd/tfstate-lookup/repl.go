@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/fujiwara/tfstate-lookup/tfstate"
+	"github.com/itchyny/gojq"
+	"github.com/mattn/go-isatty"
+)
+
+// repl reads keys or jq queries (starting with "." or "[", or containing "|") line by line from stdin and prints the results.
+type repl struct {
+	state     *tfstate.TFState
+	lookupOpt lookupOption
+	opt       outputOption
+	whole     interface{}
+	codes     map[string]*gojq.Code
+}
+
+func runREPL(state *tfstate.TFState, lookupOpt lookupOption, opt outputOption) error {
+	whole, err := state.Query(".")
+	if err != nil {
+		return err
+	}
+	r := &repl{
+		state:     state,
+		lookupOpt: lookupOption{maskSensitive: lookupOpt.maskSensitive},
+		opt:       opt,
+		whole:     whole.Value,
+		codes:     make(map[string]*gojq.Code),
+	}
+	tty := isatty.IsTerminal(os.Stdin.Fd())
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for {
+		if tty {
+			fmt.Fprint(os.Stderr, "> ")
+		}
+		if !scanner.Scan() {
+			break
+		}
+		line := strings.TrimSpace(scanner.Text())
+		switch line {
+		case "":
+			continue
+		case "exit", "quit":
+			return nil
+		}
+		if err := r.eval(line); err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+		}
+	}
+	return scanner.Err()
+}
+
+func (r *repl) eval(line string) error {
+	if !isJQQuery(line) {
+		obj, err := lookup(r.state, line, r.lookupOpt)
+		if err != nil {
+			return err
+		}
+		return printObject(obj, r.opt)
+	}
+	if r.lookupOpt.maskSensitive {
+		return errors.New("-mask-sensitive is not supported for a query of the whole state")
+	}
+	code, ok := r.codes[line]
+	if !ok {
+		q, err := gojq.Parse(line)
+		if err != nil {
+			return err
+		}
+		if code, err = gojq.Compile(q); err != nil {
+			return err
+		}
+		r.codes[line] = code
+	}
+	iter := code.Run(r.whole)
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			return nil
+		}
+		if err, ok := v.(error); ok {
+			return err
+		}
+		if err := printObject(&tfstate.Object{Value: v}, r.opt); err != nil {
+			return err
+		}
+	}
+}
+
+func isJQQuery(s string) bool {
+	return strings.HasPrefix(s, ".") || strings.HasPrefix(s, "[") || strings.Contains(s, "|")
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/fujiwara/tfstate-lookup/tfstate"
+	"github.com/mattn/go-isatty"
+)
+
+// tableQuery extracts the columns of the table from resources in the state
+const tableQuery = `[.resources[] | [
+  (.module // ""),
+  (if .mode == "data" then "data." else "" end) + .type,
+  .name,
+  (.provider // ""),
+  (.instances | length | tostring)
+]]`
+
+var tableHeader = []string{"MODULE", "TYPE", "NAME", "PROVIDER", "INSTANCES"}
+
+// printTable prints resources as an aligned table on a TTY, or tab separated lines
+func printTable(state *tfstate.TFState, types []string, opt outputOption) error {
+	obj, err := state.Query(tableQuery)
+	if err != nil {
+		return err
+	}
+	rows, _ := obj.Value.([]interface{})
+	out := opt.writer()
+	tty := isatty.IsTerminal(out.Fd())
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	if tty {
+		fmt.Fprintln(w, strings.Join(tableHeader, "\t"))
+	}
+	for _, row := range rows {
+		cols, _ := row.([]interface{})
+		if len(cols) != len(tableHeader) {
+			continue
+		}
+		values := make([]string, len(cols))
+		for i, c := range cols {
+			values[i], _ = c.(string)
+		}
+		if len(types) > 0 && !containsType(types, strings.TrimPrefix(values[1], "data.")) {
+			continue
+		}
+		if tty {
+			fmt.Fprintln(w, strings.Join(values, "\t"))
+		} else {
+			fmt.Fprintln(out, strings.Join(values, "\t"))
+		}
+	}
+	return w.Flush()
+}
+
+func containsType(types []string, t string) bool {
+	for _, v := range types {
+		if v == t {
+			return true
+		}
+	}
+	return false
+}
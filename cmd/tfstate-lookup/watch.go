@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/fujiwara/tfstate-lookup/tfstate"
+)
+
+// watchDebounce is a duration to wait for the following writes (terraform writes the state several times while applying)
+const watchDebounce = 500 * time.Millisecond
+
+// watch runs the command on start and whenever the local state files are changed until SIGINT or SIGTERM.
+func (c *cli) watch(locs []string, read func(context.Context, []string) (*tfstate.TFState, error)) error {
+	if c.interactive || c.replMode {
+		return errors.New("-watch can't be used with -i or -repl")
+	}
+	files := make(map[string]bool, len(locs))
+	for _, loc := range locs {
+		u, err := url.Parse(loc)
+		if err != nil {
+			return err
+		}
+		if loc == "-" || (u.Scheme != "" && u.Scheme != "file") {
+			return fmt.Errorf("-watch supports only local state files: %s", loc)
+		}
+		file, err := filepath.Abs(u.Path)
+		if err != nil {
+			return err
+		}
+		files[file] = true
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+	// watch the directories because the state file may be replaced by rename
+	for file := range files {
+		if err := watcher.Add(filepath.Dir(file)); err != nil {
+			return err
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	eval := func() {
+		state, err := read(ctx, locs)
+		if err == nil {
+			err = c.run(state)
+		}
+		if err != nil && ctx.Err() == nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+		}
+	}
+	eval()
+
+	timer := time.NewTimer(watchDebounce)
+	timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case ev, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if !files[filepath.Clean(ev.Name)] {
+				continue
+			}
+			if ev.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) != 0 {
+				timer.Reset(watchDebounce)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			fmt.Fprintln(os.Stderr, err.Error())
+		case <-timer.C:
+			eval()
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package tfstate
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const defaultCacheTTL = 5 * time.Minute
+
+type cacheOption struct {
+	dir string
+	ttl time.Duration
+}
+
+// cacheOptionFromEnv returns the cache option from TFSTATE_CACHE_DIR and TFSTATE_CACHE_TTL.
+// The cache is disabled when TFSTATE_CACHE_DIR is empty.
+func cacheOptionFromEnv() (cacheOption, error) {
+	opt := cacheOption{
+		dir: os.Getenv("TFSTATE_CACHE_DIR"),
+		ttl: defaultCacheTTL,
+	}
+	if ttl := os.Getenv("TFSTATE_CACHE_TTL"); ttl != "" {
+		d, err := time.ParseDuration(ttl)
+		if err != nil {
+			return opt, errors.Wrapf(err, "invalid TFSTATE_CACHE_TTL %s", ttl)
+		}
+		opt.ttl = d
+	}
+	return opt, nil
+}
+
+func (opt cacheOption) path(b *backend, ws string) (string, error) {
+	config, err := json.Marshal(b.Config)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	for _, s := range [][]byte{[]byte(b.Type), config, []byte(ws)} {
+		h.Write(s)
+		h.Write([]byte{0})
+	}
+	return filepath.Join(opt.dir, hex.EncodeToString(h.Sum(nil))+".tfstate"), nil
+}
+
+// readRemoteStateWithCache reads the remote state from the cache if it is fresh,
+// otherwise reads it from the backend and stores it to the cache.
+func readRemoteStateWithCache(ctx context.Context, b *backend, ws string, opt cacheOption) (io.ReadCloser, error) {
+	if opt.dir == "" {
+		return readRemoteState(ctx, b, ws)
+	}
+	file, err := opt.path(b, ws)
+	if err != nil {
+		return nil, err
+	}
+	if st, err := os.Stat(file); err == nil && time.Since(st.ModTime()) < opt.ttl {
+		if f, err := os.Open(file); err == nil {
+			loggerFrom(ctx).Debug("using the cached remote state", "file", file)
+			return f, nil
+		}
+	}
+
+	remote, err := readRemoteState(ctx, b, ws)
+	if err != nil {
+		return nil, err
+	}
+	defer remote.Close()
+	body, err := io.ReadAll(remote)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeCacheFile(file, body); err != nil {
+		return nil, errors.Wrap(err, "failed to write cache")
+	}
+	return io.NopCloser(bytes.NewReader(body)), nil
+}
+
+func writeCacheFile(file string, body []byte) error {
+	// the state may contain secrets
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(file), ".tfstate-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(body); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
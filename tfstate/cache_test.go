@@ -0,0 +1,56 @@
+package tfstate_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fujiwara/tfstate-lookup/tfstate"
+)
+
+func TestReadRemoteStateCache(t *testing.T) {
+	var count int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count++
+		http.ServeFile(w, r, "test/terraform.tfstate")
+	}))
+	defer ts.Close()
+
+	dir := t.TempDir()
+	t.Setenv("TFSTATE_CACHE_DIR", dir)
+	t.Setenv("TFSTATE_CACHE_TTL", "1m")
+
+	file := writeBackendState(t, "http", map[string]interface{}{
+		"address": ts.URL + "/state",
+	}, "")
+	for i := 0; i < 3; i++ {
+		state, err := tfstate.ReadFile(context.Background(), file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		testLookupState(t, state)
+	}
+	if count != 1 {
+		t.Errorf("remote state must be fetched once, but fetched %d times", count)
+	}
+
+	// expire the cache
+	caches, _ := filepath.Glob(filepath.Join(dir, "*.tfstate"))
+	if len(caches) != 1 {
+		t.Fatalf("unexpected cache files %v", caches)
+	}
+	past := time.Now().Add(-2 * time.Minute)
+	if err := os.Chtimes(caches[0], past, past); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tfstate.ReadFile(context.Background(), file); err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 {
+		t.Errorf("expired cache must be refreshed, but fetched %d times", count)
+	}
+}
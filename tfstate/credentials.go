@@ -0,0 +1,60 @@
+package tfstate
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// Credentials are credentials for a remote backend provided by CredentialProvider.
+// Empty fields fall back to the backend config and the default credential chains.
+type Credentials struct {
+	// AWS static credentials for the s3 backend
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+
+	// service account key (JSON content or a file path) for the gcs backend
+	GoogleCredentials string
+
+	// storage account access key or SAS token for the azurerm backend
+	AzureAccessKey string
+	AzureSASToken  string
+}
+
+// CredentialProvider provides credentials for the backend type (s3, gcs or azurerm) and the backend config in tfstate.
+// It may return nil to use the default credentials.
+type CredentialProvider interface {
+	Credentials(ctx context.Context, backendType string, config map[string]interface{}) (*Credentials, error)
+}
+
+// CredentialProviderFunc is a function which implements CredentialProvider.
+type CredentialProviderFunc func(ctx context.Context, backendType string, config map[string]interface{}) (*Credentials, error)
+
+// Credentials calls f(ctx, backendType, config).
+func (f CredentialProviderFunc) Credentials(ctx context.Context, backendType string, config map[string]interface{}) (*Credentials, error) {
+	return f(ctx, backendType, config)
+}
+
+type credentialProviderKey struct{}
+
+// withCredentialProvider returns a context which carries the provider to the backends.
+func withCredentialProvider(ctx context.Context, p CredentialProvider) context.Context {
+	return context.WithValue(ctx, credentialProviderKey{}, p)
+}
+
+// credentialsFor returns the credentials of the provider in the context, or empty credentials without a provider.
+func credentialsFor(ctx context.Context, backendType string, config map[string]interface{}) (Credentials, error) {
+	p, ok := ctx.Value(credentialProviderKey{}).(CredentialProvider)
+	if !ok || p == nil {
+		return Credentials{}, nil
+	}
+	creds, err := p.Credentials(ctx, backendType, config)
+	if err != nil {
+		return Credentials{}, errors.Wrapf(err, "failed to get credentials for %s backend", backendType)
+	}
+	if creds == nil {
+		return Credentials{}, nil
+	}
+	return *creds, nil
+}
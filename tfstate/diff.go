@@ -0,0 +1,66 @@
+package tfstate
+
+import (
+	"sort"
+)
+
+// Change actions of Diff
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeChanged = "changed"
+)
+
+// Change represents a changed attribute between two states.
+// Before and After are flattened values as Flatten returns.
+type Change struct {
+	Key    string `json:"key"`
+	Action string `json:"action"`
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+}
+
+// Diff returns changed attributes of resources and outputs from the state a to the state b in sorted order by the key.
+func Diff(a, b *TFState) ([]Change, error) {
+	return diff(a.flattenState(nil), b.flattenState(nil)), nil
+}
+
+// DiffMasked returns changed attributes as Diff does,
+// and values of sensitive outputs and sensitive_attributes are replaced by the mask.
+// Changes of sensitive values are reported with the masked values.
+func DiffMasked(a, b *TFState, mask interface{}) ([]Change, error) {
+	changes := diff(a.flattenState(nil), b.flattenState(nil))
+	maskedBefore, maskedAfter := a.flattenState(&mask), b.flattenState(&mask)
+	masked := Object{mask}.String()
+	for i, c := range changes {
+		if v, ok := maskedBefore[c.Key]; c.Action != ChangeAdded && (!ok || v != c.Before) {
+			changes[i].Before = masked
+		}
+		if v, ok := maskedAfter[c.Key]; c.Action != ChangeRemoved && (!ok || v != c.After) {
+			changes[i].After = masked
+		}
+	}
+	return changes, nil
+}
+
+func diff(before, after map[string]string) []Change {
+	changes := make([]Change, 0)
+	for key, bv := range before {
+		av, ok := after[key]
+		switch {
+		case !ok:
+			changes = append(changes, Change{Key: key, Action: ChangeRemoved, Before: bv})
+		case av != bv:
+			changes = append(changes, Change{Key: key, Action: ChangeChanged, Before: bv, After: av})
+		}
+	}
+	for key, av := range after {
+		if _, ok := before[key]; !ok {
+			changes = append(changes, Change{Key: key, Action: ChangeAdded, After: av})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Key < changes[j].Key
+	})
+	return changes
+}
@@ -0,0 +1,81 @@
+package tfstate_test
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/fujiwara/tfstate-lookup/tfstate"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDiff(t *testing.T) {
+	b, err := os.ReadFile("test/terraform.tfstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := tfstate.Read(context.Background(), strings.NewReader(string(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	modified := strings.Replace(string(b), `"value": "FOO"`, `"value": "FOO2"`, 1)
+	modified = strings.Replace(modified, `"retention_in_days": 30,`, `"retention_in_days": 30, "new_attr": "x",`, 1)
+	modified = strings.Replace(modified, `"kms_key_id": "",`, ``, 1)
+	c, err := tfstate.Read(context.Background(), strings.NewReader(modified))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := tfstate.Diff(a, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("unexpected changes %v", changes)
+	}
+
+	changes, err = tfstate.Diff(a, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []tfstate.Change{
+		{Key: `module.logs.aws_cloudwatch_log_group.main.kms_key_id`, Action: tfstate.ChangeRemoved},
+		{Key: `module.logs.aws_cloudwatch_log_group.main.new_attr`, Action: tfstate.ChangeAdded, After: "x"},
+		{Key: `output.foo`, Action: tfstate.ChangeChanged, Before: "FOO", After: "FOO2"},
+	}
+	if diff := cmp.Diff(changes, expected); diff != "" {
+		t.Errorf("unexpected changes %s", diff)
+	}
+}
+
+func TestDiffMasked(t *testing.T) {
+	b, err := os.ReadFile("test/terraform.tfstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := tfstate.Read(context.Background(), strings.NewReader(string(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	modified := strings.Replace(string(b), `"value": "FOO"`, `"value": "FOO2"`, 1)
+	modified = strings.Replace(modified, `"value": "s3cr3t"`, `"value": "s3cr3t2"`, 1)
+	// tags.env of main["web"] is sensitive
+	modified = strings.Replace(modified, "\"env\": \"world\"\n            }\n          },\n          \"sensitive_attributes\"", "\"env\": \"world2\"\n            }\n          },\n          \"sensitive_attributes\"", 1)
+	c, err := tfstate.Read(context.Background(), strings.NewReader(modified))
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes, err := tfstate.DiffMasked(a, c, "***")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []tfstate.Change{
+		{Key: `module.logs.aws_cloudwatch_log_group.main["web"].tags.env`, Action: tfstate.ChangeChanged, Before: "***", After: "***"},
+		{Key: `output.foo`, Action: tfstate.ChangeChanged, Before: "FOO", After: "FOO2"},
+		{Key: `output.secret`, Action: tfstate.ChangeChanged, Before: "***", After: "***"},
+	}
+	if diff := cmp.Diff(changes, expected); diff != "" {
+		t.Errorf("unexpected changes %s", diff)
+	}
+}
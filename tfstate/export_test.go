@@ -2,6 +2,7 @@ package tfstate
 
 import (
 	"context"
+	"io"
 
 	"github.com/aws/aws-sdk-go-v2/config"
 )
@@ -14,3 +15,33 @@ func GetBucketRegion(bucket string) (string, error) {
 	}
 	return getBucketRegion(ctx, cfg, bucket)
 }
+
+func ReadTFE(ctx context.Context, address, organization, ws, token string) (io.ReadCloser, error) {
+	return readTFE(ctx, address, organization, ws, token)
+}
+
+func S3Key(config map[string]interface{}, ws string) string {
+	return s3Key(config, ws)
+}
+
+func ModulePaths(module string) []string {
+	return modulePaths(module)
+}
+
+func QuoteJQQuery(query string) string {
+	return quoteJQQuery(query)
+}
+
+func CanonicalIndexKeys(key string) string {
+	return canonicalIndexKeys(key)
+}
+
+func GCSCredentials(p CredentialProvider, config map[string]interface{}) (string, error) {
+	opt, err := gcsOptionFromConfig(context.WithValue(context.Background(), credentialProviderKey{}, p), config)
+	return opt.credentials, err
+}
+
+func AzureRMKeys(p CredentialProvider, config map[string]interface{}) (accessKey, sasToken string, err error) {
+	opt, err := azureRMOptionFromConfig(context.WithValue(context.Background(), credentialProviderKey{}, p), config)
+	return opt.accessKey, opt.sasToken, err
+}
@@ -29,7 +29,9 @@ func FuncMapWithName(ctx context.Context, name string, stateLoc string) (templat
 			addrs = strings.ReplaceAll(addrs, "'", "\"")
 		}
 		attrs, err := state.Lookup(addrs)
-		if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			panic(fmt.Sprintf("%s is not found in tfstate", addrs))
+		} else if err != nil {
 			panic(fmt.Sprintf("failed to lookup %s in tfstate: %s", addrs, err))
 		}
 		if attrs.Value == nil {
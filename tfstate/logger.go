@@ -0,0 +1,28 @@
+package tfstate
+
+import "context"
+
+// Logger is a logger for debug messages about reading tfstate, such as the resolved backend and the remote location.
+// args are alternating keys and values. *slog.Logger satisfies it.
+type Logger interface {
+	Debug(msg string, args ...interface{})
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...interface{}) {}
+
+type loggerKey struct{}
+
+// withLogger returns a context which carries the logger to the backends.
+func withLogger(ctx context.Context, l Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// loggerFrom returns the logger in the context, or a no-op logger.
+func loggerFrom(ctx context.Context) Logger {
+	if l, ok := ctx.Value(loggerKey{}).(Logger); ok && l != nil {
+		return l
+	}
+	return nopLogger{}
+}
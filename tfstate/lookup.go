@@ -1,19 +1,25 @@
 package tfstate
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/fs"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 
 	"github.com/itchyny/gojq"
 	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
 )
 
 const (
@@ -25,6 +31,13 @@ var (
 	defaultWorkspeceKeyPrefix = "env:"
 )
 
+// ErrNotFound is returned when the key is not found in the state.
+// Use errors.Is(err, ErrNotFound) for wrapped errors.
+var ErrNotFound = errors.New("not found in the state")
+
+// ErrWorkspaceNotSupported is returned by ReadURLWithWorkspace when the URL scheme has no workspaces.
+var ErrWorkspaceNotSupported = errors.New("workspace is not supported")
+
 type Object struct {
 	Value interface{}
 }
@@ -39,17 +52,217 @@ func (a Object) Bytes() []byte {
 	}
 }
 
+// MarshalJSON encodes the value, not the Object itself.
+func (a Object) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.Value)
+}
+
+// YAML returns the value encoded in YAML with 2 spaces indentation.
+func (a Object) YAML() ([]byte, error) {
+	var b bytes.Buffer
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err := enc.Encode(a.Value); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// BytesIndent returns the value as Bytes does, but non-string values are indented like json.MarshalIndent.
+func (a Object) BytesIndent(prefix, indent string) []byte {
+	switch v := (a.Value).(type) {
+	case string:
+		return []byte(v)
+	default:
+		b, _ := json.MarshalIndent(v, prefix, indent)
+		return b
+	}
+}
+
 func (a Object) String() string {
 	return string(a.Bytes())
 }
 
-// Query queries object by go-jq
+// Int returns the value as an integer.
+// Strings such as attributes_flat of old states are parsed.
+func (a Object) Int() (int64, error) {
+	switch v := (a.Value).(type) {
+	case int:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case float64:
+		if i := int64(v); float64(i) == v {
+			return i, nil
+		}
+	case json.Number:
+		return v.Int64()
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	}
+	return 0, errors.Errorf("%s is not an integer", a.String())
+}
+
+// Float returns the value as a float.
+// Strings such as attributes_flat of old states are parsed.
+func (a Object) Float() (float64, error) {
+	switch v := (a.Value).(type) {
+	case int:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case json.Number:
+		return v.Float64()
+	case string:
+		return strconv.ParseFloat(v, 64)
+	}
+	return 0, errors.Errorf("%s is not a number", a.String())
+}
+
+// Bool returns the value as a bool.
+// Strings such as attributes_flat of old states are parsed.
+func (a Object) Bool() (bool, error) {
+	switch v := (a.Value).(type) {
+	case bool:
+		return v, nil
+	case string:
+		return strconv.ParseBool(v)
+	}
+	return false, errors.Errorf("%s is not a bool", a.String())
+}
+
+// Slice returns elements of the list value as Objects.
+func (a Object) Slice() ([]Object, error) {
+	l, ok := (a.Value).([]interface{})
+	if !ok {
+		return nil, errors.Errorf("%s is not a list", a.String())
+	}
+	objs := make([]Object, 0, len(l))
+	for _, v := range l {
+		objs = append(objs, Object{v})
+	}
+	return objs, nil
+}
+
+// Map returns values of the object value as Objects keyed by the attribute names.
+func (a Object) Map() (map[string]Object, error) {
+	m, ok := (a.Value).(map[string]interface{})
+	if !ok {
+		return nil, errors.Errorf("%s is not an object", a.String())
+	}
+	objs := make(map[string]Object, len(m))
+	for k, v := range m {
+		objs[k] = Object{v}
+	}
+	return objs, nil
+}
+
+// Get returns the value at the path without jq, such as Get("tags", "Name") and Get("list", "0").
+// A numeric string indexes a list, and a negative one indexes from the end.
+// It returns ErrNotFound (wrapped) when the key or the index does not exist.
+func (a Object) Get(path ...string) (*Object, error) {
+	v := a.Value
+	for i, p := range path {
+		switch vv := v.(type) {
+		case map[string]interface{}:
+			e, ok := vv[p]
+			if !ok {
+				return nil, errors.Wrapf(ErrNotFound, "%s", strings.Join(path[:i+1], "."))
+			}
+			v = e
+		case []interface{}:
+			n, err := strconv.Atoi(p)
+			if err != nil {
+				return nil, errors.Errorf("invalid index %s of %s", p, strings.Join(path[:i], "."))
+			}
+			if n < 0 {
+				n += len(vv)
+			}
+			if n < 0 || n >= len(vv) {
+				return nil, errors.Wrapf(ErrNotFound, "%s", strings.Join(path[:i+1], "."))
+			}
+			v = vv[n]
+		default:
+			return nil, errors.Errorf("%s is not an object or a list", strings.Join(path[:i], "."))
+		}
+	}
+	return &Object{v}, nil
+}
+
+// Keys returns the sorted keys of the object value.
+func (a Object) Keys() ([]string, error) {
+	m, ok := (a.Value).(map[string]interface{})
+	if !ok {
+		return nil, errors.Errorf("%s is not an object", a.String())
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// Len returns the number of elements of the list value, or 0 for other values.
+func (a Object) Len() int {
+	l, _ := (a.Value).([]interface{})
+	return len(l)
+}
+
+// Each calls fn for each element of the list value in order, and stops at the first error of fn.
+func (a Object) Each(fn func(i int, o Object) error) error {
+	l, ok := (a.Value).([]interface{})
+	if !ok {
+		return errors.Errorf("%s is not a list", a.String())
+	}
+	for i, v := range l {
+		if err := fn(i, Object{v}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Query queries object by go-jq.
+// It returns ErrNotFound (wrapped) when the query has no results or the path of the query does not exist,
+// and an Object of nil for the path which exists with null.
 func (a *Object) Query(query string) (*Object, error) {
+	return a.QueryWithVars(query, nil)
+}
+
+// QueryWithVars queries object by go-jq as Query does, with the variables such as {"name": "foo"} for `.[$name]`.
+// The names of the variables may be prefixed with "$".
+func (a *Object) QueryWithVars(query string, vars map[string]interface{}) (*Object, error) {
+	return a.query(context.Background(), query, vars)
+}
+
+func (a *Object) query(ctx context.Context, query string, vars map[string]interface{}) (*Object, error) {
 	jq, err := gojq.Parse(query)
 	if err != nil {
 		return nil, err
 	}
-	iter := jq.Run(a.Value)
+	names := make([]string, 0, len(vars))
+	for name := range vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	values := make([]interface{}, 0, len(names))
+	for i, name := range names {
+		values = append(values, vars[name])
+		if !strings.HasPrefix(name, "$") {
+			names[i] = "$" + name
+		}
+	}
+	code, err := gojq.Compile(jq, gojq.WithVariables(names))
+	if err != nil {
+		return nil, err
+	}
+	iter := code.RunWithContext(ctx, a.Value, values...)
 	for {
 		v, ok := iter.Next()
 		if !ok {
@@ -58,16 +271,127 @@ func (a *Object) Query(query string) (*Object, error) {
 		if err, ok := v.(error); ok {
 			return nil, err
 		}
+		if v == nil && !exists(a.Value, query, names, values) {
+			return nil, errors.Wrapf(ErrNotFound, "%s", query)
+		}
 		return &Object{v}, nil
 	}
-	return nil, errors.Errorf("%s is not found in the state", query)
+	return nil, errors.Wrapf(ErrNotFound, "no results of %s", query)
 }
 
+// Exists reports whether the path of the query exists in the object, even if the value is null.
+// Queries which are not paths such as `.tags | keys` are reported as existing.
+func (a Object) Exists(query string) bool {
+	return exists(a.Value, query, nil, nil)
+}
+
+// exists reports whether the path of the query exists in v, such as false for a missing key or an index out of range.
+// It reports true for the queries which are not paths such as `.tags | keys`.
+// names and values are the variables of the query for gojq.WithVariables.
+func exists(v interface{}, query string, names []string, values []interface{}) bool {
+	jq, err := gojq.Parse("path(" + query + ")")
+	if err != nil {
+		return true
+	}
+	code, err := gojq.Compile(jq, gojq.WithVariables(names))
+	if err != nil {
+		return true
+	}
+	p, ok := code.Run(v, values...).Next()
+	if !ok {
+		return true
+	}
+	path, ok := p.([]interface{})
+	if !ok {
+		return true // error
+	}
+	for _, step := range path {
+		switch st := step.(type) {
+		case string:
+			m, ok := v.(map[string]interface{})
+			if !ok {
+				return false
+			}
+			if v, ok = m[st]; !ok {
+				return false
+			}
+		case int:
+			l, ok := v.([]interface{})
+			if !ok {
+				return false
+			}
+			if st < 0 {
+				st += len(l)
+			}
+			if st < 0 || st >= len(l) {
+				return false
+			}
+			v = l[st]
+		default:
+			return true
+		}
+	}
+	return true
+}
+
+// Flatten returns flattened keys and values of the object such as {"prefix.tags.Name": "main", "prefix.list[0]": "a"}.
+// A string value is as is, others are JSON encoded.
+func (a Object) Flatten(prefix string) map[string]string {
+	m := make(map[string]string)
+	flatten(m, prefix, a.Value)
+	return m
+}
+
+// Paths returns sorted paths of all leaf values in the object such as [".id", ".list[0]", ".tags.Name"].
+// The paths can be appended to the key of Lookup.
+func (a Object) Paths() []string {
+	m := a.Flatten("")
+	paths := make([]string, 0, len(m))
+	for p := range m {
+		if p != "" {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func flatten(m map[string]string, key string, v interface{}) {
+	switch vv := v.(type) {
+	case map[string]interface{}:
+		if len(vv) == 0 {
+			break
+		}
+		for k, e := range vv {
+			if flatKey.MatchString(k) {
+				flatten(m, key+"."+k, e)
+			} else {
+				flatten(m, key+"["+strconv.Quote(k)+"]", e)
+			}
+		}
+		return
+	case []interface{}:
+		if len(vv) == 0 {
+			break
+		}
+		for i, e := range vv {
+			flatten(m, key+"["+strconv.Itoa(i)+"]", e)
+		}
+		return
+	}
+	m[key] = Object{v}.String()
+}
+
+// flatKey matches keys which can be looked up in the dotted form (quoteJQQuery quotes hyphenated keys)
+var flatKey = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_-]*$`)
+
 // TFState represents a tfstate
 type TFState struct {
 	state   tfstate
+	raw     json.RawMessage
 	scanned map[string]instance
 	once    sync.Once
+	backend *backend // the backend which the state was read from
 }
 
 type tfstate struct {
@@ -76,10 +400,30 @@ type tfstate struct {
 	Backend          *backend               `json:"backend"`
 	Version          int                    `json:"version"`
 	TerraformVersion string                 `json:"terraform_version"`
-	Serial           int                    `json:"serial"`
+	Serial           int64                  `json:"serial"`
 	Lineage          string                 `json:"lineage"`
 }
 
+// FormatVersion returns the format version of tfstate (currently 4).
+func (s *TFState) FormatVersion() int {
+	return s.state.Version
+}
+
+// TerraformVersion returns the version of Terraform which wrote tfstate last.
+func (s *TFState) TerraformVersion() string {
+	return s.state.TerraformVersion
+}
+
+// Serial returns the serial of tfstate, which is incremented on every write.
+func (s *TFState) Serial() int64 {
+	return s.state.Serial
+}
+
+// Lineage returns the lineage of tfstate, which is unique for a state and its snapshots.
+func (s *TFState) Lineage() string {
+	return s.state.Lineage
+}
+
 func outputValue(v interface{}) interface{} {
 	if mv, ok := v.(map[string]interface{}); ok {
 		if mv["value"] != nil && mv["type"] != nil {
@@ -112,8 +456,13 @@ type instance struct {
 	Attributes     interface{}     `json:"attributes"`
 	AttributesFlat interface{}     `json:"attributes_flat"`
 	Private        string          `json:"private"`
+	Deposed        string          `json:"deposed"`
+
+	SensitiveAttributes []sensitivePath `json:"sensitive_attributes"`
 
-	data interface{}
+	data         interface{}
+	resourceType string
+	sensitive    bool
 }
 
 // Read reads a tfstate from io.Reader
@@ -123,20 +472,49 @@ func Read(ctx context.Context, src io.Reader) (*TFState, error) {
 
 // ReadWithWorkspace reads a tfstate from io.Reader with workspace
 func ReadWithWorkspace(ctx context.Context, src io.Reader, ws string) (*TFState, error) {
+	return read(ctx, src, &readOptions{workspace: ws})
+}
+
+func read(ctx context.Context, src io.Reader, o *readOptions) (*TFState, error) {
+	ws := o.workspace
 	if ws == "" {
 		ws = defaultWorkspace
 	}
 	var s TFState
-	if err := json.NewDecoder(src).Decode(&s.state); err != nil {
+	if err := json.NewDecoder(src).Decode(&s.raw); err != nil {
+		return nil, errors.Wrap(err, "invalid json")
+	}
+	if err := json.Unmarshal(s.raw, &s.state); err != nil {
 		return nil, errors.Wrap(err, "invalid json")
 	}
 	if s.state.Backend != nil {
-		remote, err := readRemoteState(ctx, s.state.Backend, ws)
+		cacheOpt, err := o.cacheOption()
+		if err != nil {
+			return nil, err
+		}
+		if o.credentialProvider != nil {
+			ctx = withCredentialProvider(ctx, o.credentialProvider)
+		}
+		if o.logger != nil {
+			ctx = withLogger(ctx, o.logger)
+		}
+		remote, err := readRemoteStateWithCache(ctx, s.state.Backend, ws, cacheOpt)
 		if err != nil {
 			return nil, err
 		}
 		defer remote.Close()
-		return Read(ctx, remote)
+		// the remote state is read in the default workspace
+		remoteOpt := *o
+		remoteOpt.workspace = ""
+		st, err := read(ctx, remote, &remoteOpt)
+		if err != nil {
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return nil, errors.Wrapf(err, "timed out reading the state from %s", describeBackend(s.state.Backend, ws))
+			}
+			return nil, err
+		}
+		st.backend = s.state.Backend
+		return st, nil
 	}
 	if s.state.Version != StateVersion {
 		return nil, errors.Errorf("unsupported state version %d", s.state.Version)
@@ -146,34 +524,109 @@ func ReadWithWorkspace(ctx context.Context, src io.Reader, ws string) (*TFState,
 
 // ReadFile reads terraform.tfstate from the file (a workspace reads from environment file in the same directory)
 func ReadFile(ctx context.Context, file string) (*TFState, error) {
-	ws, _ := os.ReadFile(filepath.Join(filepath.Dir(file), "environment"))
-	// if not exist, don't care (using default workspace)
+	return ReadFileWithWorkspace(ctx, file, "")
+}
 
-	f, err := os.Open(file)
+// ReadFileWithWorkspace reads terraform.tfstate from the file with workspace.
+// If ws is empty, a workspace reads from environment file in the same directory.
+func ReadFileWithWorkspace(ctx context.Context, file string, ws string) (*TFState, error) {
+	return readFile(ctx, file, &readOptions{workspace: ws})
+}
+
+func readFile(ctx context.Context, file string, o *readOptions) (*TFState, error) {
+	open := func(name string) (io.ReadCloser, error) {
+		return os.Open(name)
+	}
+	return readFileWith(ctx, open, file, filepath.Join(filepath.Dir(file), "environment"), o)
+}
+
+// ReadFS reads terraform.tfstate from the file in fsys (a workspace reads from environment file in the same directory of fsys).
+func ReadFS(fsys fs.FS, name string, opts ...ReadOption) (*TFState, error) {
+	o := newReadOptions(opts)
+	ctx, cancel := o.context()
+	defer cancel()
+	open := func(name string) (io.ReadCloser, error) {
+		return fsys.Open(name)
+	}
+	return readFileWith(ctx, open, name, path.Join(path.Dir(name), "environment"), o)
+}
+
+func readAll(open func(string) (io.ReadCloser, error), name string) ([]byte, error) {
+	f, err := open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// readFileWith reads the file opened by open, in the workspace of the option or of the environment file.
+func readFileWith(ctx context.Context, open func(string) (io.ReadCloser, error), file, envFile string, o *readOptions) (*TFState, error) {
+	ws := o.workspace
+	if ws == "" {
+		b, err := readAll(open, envFile)
+		if err == nil && len(b) == 0 {
+			err = errors.New("empty workspace")
+		}
+		if err != nil && o.strictWorkspace {
+			return nil, errors.Wrapf(err, "failed to read the workspace from %s", envFile)
+		}
+		// if not exist, don't care (using default workspace)
+		ws = string(b)
+	}
+
+	f, err := open(file)
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to read tfstate from %s", file)
 	}
 	defer f.Close()
-	return ReadWithWorkspace(ctx, f, string(ws))
+	fileOpt := *o
+	fileOpt.workspace = ws
+	return read(ctx, f, &fileOpt)
 }
 
 // ReadURL reads terraform.tfstate from the URL.
 func ReadURL(ctx context.Context, loc string) (*TFState, error) {
+	return ReadURLWithWorkspace(ctx, loc, "")
+}
+
+// ReadURLWithWorkspace reads terraform.tfstate from the URL with workspace.
+// The workspace is supported for s3, azurerm, file URLs and local paths.
+// An empty workspace means the default workspace (or the environment file for local paths).
+func ReadURLWithWorkspace(ctx context.Context, loc string, ws string) (*TFState, error) {
 	u, err := url.Parse(loc)
 	if err != nil {
 		return nil, err
 	}
+	urlWorkspace := ws
+	if urlWorkspace == "" {
+		urlWorkspace = defaultWorkspace
+	}
+	switch u.Scheme {
+	case "", "file":
+		if ws != "" {
+			return ReadFileWithWorkspace(ctx, u.Path, ws)
+		}
+	case "s3", "azurerm":
+	default:
+		if urlWorkspace != defaultWorkspace {
+			return nil, errors.Wrapf(ErrWorkspaceNotSupported, "failed to read tfstate from %s in workspace %s", u.String(), ws)
+		}
+	}
 
 	var src io.ReadCloser
 	switch u.Scheme {
 	case "http", "https":
-		src, err = readHTTP(ctx, u.String())
+		src, err = readHTTP(ctx, u.String(), httpOption{})
 	case "s3":
-		key := strings.TrimPrefix(u.Path, "/")
-		src, err = readS3(ctx, u.Host, key, s3Option{})
+		// s3://bucket/key?region=ap-northeast-1 is read as the s3 backend config
+		config := urlQueryConfig(u)
+		config["bucket"] = u.Host
+		config["key"] = strings.TrimPrefix(u.Path, "/")
+		src, err = readS3State(ctx, config, urlWorkspace)
 	case "gs":
 		key := strings.TrimPrefix(u.Path, "/")
-		src, err = readGCS(ctx, u.Host, key, "", os.Getenv("GOOGLE_ENCRYPTION_KEY"))
+		src, err = readGCS(ctx, u.Host, key, gcsOption{encryption_key: os.Getenv("GOOGLE_ENCRYPTION_KEY")})
 	case "azurerm":
 		split := strings.SplitN(u.Path, "/", 4)
 
@@ -182,27 +635,242 @@ func ReadURL(ctx context.Context, loc string) (*TFState, error) {
 			break
 		}
 
-		src, err = readAzureRM(ctx, u.Host, split[1], split[2], split[3], azureRMOption{subscriptionID: u.User.Username()})
+		config := urlQueryConfig(u)
+		config["resource_group_name"] = u.Host
+		config["storage_account_name"] = split[1]
+		config["container_name"] = split[2]
+		config["key"] = split[3]
+		if sub := u.User.Username(); sub != "" {
+			config["subscription_id"] = sub
+		}
+		src, err = readAzureRMState(ctx, config, urlWorkspace)
 	case "file":
 		src, err = os.Open(u.Path)
 	case "remote":
 		split := strings.Split(u.Path, "/")
-		src, err = readTFE(ctx, u.Host, split[1], split[2], "")
+		if len(split) < 3 {
+			err = fmt.Errorf("invalid remote url: %s", u.String())
+			break
+		}
+		src, err = readTFE(ctx, tfeAddress(u.Host), split[1], split[2], "")
 	case "":
 		return ReadFile(ctx, u.Path)
 	default:
 		err = errors.Errorf("URL scheme %s is not supported", u.Scheme)
 	}
 	if err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, errors.Wrapf(err, "timed out reading tfstate from %s", u.String())
+		}
 		return nil, errors.Wrapf(err, "failed to read tfstate from %s", u.String())
 	}
 	defer src.Close()
-	return Read(ctx, src)
+	st, err := Read(ctx, src)
+	if err != nil && u.Scheme != "file" && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, errors.Wrapf(err, "timed out reading tfstate from %s", u.String())
+	}
+	return st, err
 }
 
-// Lookup lookups attributes of the specified key in tfstate
+// urlQueryConfig returns a backend config from the query parameters of the URL.
+func urlQueryConfig(u *url.URL) map[string]interface{} {
+	q := u.Query()
+	config := make(map[string]interface{}, len(q))
+	for k := range q {
+		config[k] = q.Get(k)
+	}
+	return config
+}
+
+// Merge merges the states into a new state.
+// When the same resource or output exists in multiple states, the first one wins
+// and the conflicted names are returned in sorted order.
+// Other top-level fields such as terraform_version are taken from the first state.
+func Merge(states ...*TFState) (*TFState, []string, error) {
+	m := &TFState{
+		state: tfstate{
+			Version: StateVersion,
+			Outputs: make(map[string]interface{}),
+		},
+	}
+	mergedDoc := map[string]json.RawMessage{"version": json.RawMessage(strconv.Itoa(StateVersion))}
+	mergedOutputs := make(map[string]json.RawMessage)
+	mergedResources := make([]json.RawMessage, 0)
+	seen := make(map[string]bool)
+	conflicts := make([]string, 0)
+	for i, s := range states {
+		doc, rawResources, err := s.rawDocument()
+		if err != nil {
+			return nil, nil, err
+		}
+		if i == 0 {
+			mergedDoc = doc
+		}
+		var rawOutputs map[string]json.RawMessage
+		if o, ok := doc["outputs"]; ok {
+			if err := json.Unmarshal(o, &rawOutputs); err != nil {
+				return nil, nil, errors.Wrap(err, "invalid outputs")
+			}
+		}
+		for name, v := range s.state.Outputs {
+			if _, ok := m.state.Outputs[name]; ok {
+				conflicts = append(conflicts, "output."+name)
+				continue
+			}
+			m.state.Outputs[name] = v
+			mergedOutputs[name] = rawOutputs[name]
+		}
+		for j, r := range s.state.Resources {
+			addr := r.address(nil)
+			if seen[addr] {
+				conflicts = append(conflicts, addr)
+				continue
+			}
+			seen[addr] = true
+			m.state.Resources = append(m.state.Resources, r)
+			mergedResources = append(mergedResources, rawResources[j])
+		}
+	}
+	outputs, err := json.Marshal(mergedOutputs)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to encode outputs")
+	}
+	doc := make(map[string]json.RawMessage, len(mergedDoc))
+	for k, v := range mergedDoc {
+		doc[k] = v
+	}
+	doc["outputs"] = outputs
+	if m.raw, err = marshalDocument(doc, mergedResources); err != nil {
+		return nil, nil, err
+	}
+	if err := json.Unmarshal(m.raw, &m.state); err != nil {
+		return nil, nil, errors.Wrap(err, "invalid json")
+	}
+	sort.Strings(conflicts)
+	return m, conflicts, nil
+}
+
+// FilterProvider returns a new state which has only resources of the provider and outputs.
+// The provider is matched as a substring of the provider in tfstate such as `provider["registry.terraform.io/hashicorp/aws"].us_east_1`,
+// so an alias (us_east_1) or a source (hashicorp/aws) can be specified.
+// The raw document keeps the other fields of tfstate as is.
+func (s *TFState) FilterProvider(provider string) (*TFState, error) {
+	doc, rawResources, err := s.rawDocument()
+	if err != nil {
+		return nil, err
+	}
+	f := &TFState{state: s.state, backend: s.backend}
+	f.state.Resources = make([]resource, 0, len(s.state.Resources))
+	filtered := make([]json.RawMessage, 0, len(rawResources))
+	for i, r := range s.state.Resources {
+		if strings.Contains(r.Provider, provider) {
+			f.state.Resources = append(f.state.Resources, r)
+			filtered = append(filtered, rawResources[i])
+		}
+	}
+	if f.raw, err = marshalDocument(doc, filtered); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// rawDocument returns the top-level fields and the resources of the raw tfstate as raw JSON,
+// to build a new document without losing fields which are not decoded into the internal structs.
+func (s *TFState) rawDocument() (map[string]json.RawMessage, []json.RawMessage, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(s.raw, &doc); err != nil {
+		return nil, nil, errors.Wrap(err, "invalid json")
+	}
+	var resources []json.RawMessage
+	if r, ok := doc["resources"]; ok {
+		if err := json.Unmarshal(r, &resources); err != nil {
+			return nil, nil, errors.Wrap(err, "invalid resources")
+		}
+	}
+	if len(resources) != len(s.state.Resources) {
+		return nil, nil, errors.Errorf("unexpected number of resources %d (decoded %d)", len(resources), len(s.state.Resources))
+	}
+	return doc, resources, nil
+}
+
+// marshalDocument returns the document with the resources.
+func marshalDocument(doc map[string]json.RawMessage, resources []json.RawMessage) (json.RawMessage, error) {
+	r, err := json.Marshal(resources)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode resources")
+	}
+	doc["resources"] = r
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode tfstate")
+	}
+	return b, nil
+}
+
+// Lookup lookups attributes of the specified key in tfstate.
+// It returns NotFoundError, which wraps ErrNotFound, when the resource or the attribute does not exist.
 func (s *TFState) Lookup(key string) (*Object, error) {
+	return s.lookup(context.Background(), key, nil)
+}
+
+// LookupContext lookups attributes of the specified key in tfstate as Lookup does.
+// The query of the attributes is cancelled by the context.
+func (s *TFState) LookupContext(ctx context.Context, key string) (*Object, error) {
+	return s.lookup(ctx, key, nil)
+}
+
+// LookupMasked lookups attributes of the specified key in tfstate as Lookup does,
+// and values of sensitive outputs and sensitive_attributes are replaced by the mask.
+func (s *TFState) LookupMasked(key string, mask interface{}) (*Object, error) {
+	return s.lookup(context.Background(), key, &mask)
+}
+
+// LookupErrors are errors of the keys in LookupAll.
+type LookupErrors map[string]error
+
+func (e LookupErrors) Error() string {
+	keys := make([]string, 0, len(e))
+	for key := range e {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	msgs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		msgs = append(msgs, e[key].Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// LookupAll lookups all keys in tfstate and returns the results keyed by the key.
+// It doesn't stop at a failed key. The failed keys are not in the results and returned as LookupErrors.
+func (s *TFState) LookupAll(keys []string) (map[string]*Object, error) {
+	results := make(map[string]*Object, len(keys))
+	errs := make(LookupErrors)
+	for _, key := range keys {
+		obj, err := s.Lookup(key)
+		if err != nil {
+			errs[key] = err
+			continue
+		}
+		results[key] = obj
+	}
+	if len(errs) > 0 {
+		return results, errs
+	}
+	return results, nil
+}
+
+func (s *TFState) lookup(ctx context.Context, key string, mask *interface{}) (*Object, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	s.once.Do(s.scan)
+	key = canonicalIndexKeys(strings.TrimSpace(key)) // addresses copied from terraform plan output
+	if i := strings.Index(key, wildcardIndex); i > 0 {
+		if obj, ok, err := s.lookupWildcard(ctx, key[:i], key[i+len(wildcardIndex):], mask); ok {
+			return obj, err
+		}
+	}
 	var found instance
 	var foundName string
 	for name, ins := range s.scanned {
@@ -215,7 +883,7 @@ func (s *TFState) Lookup(key string) (*Object, error) {
 		}
 	}
 	if foundName == "" {
-		return &Object{}, nil
+		return nil, s.notFound(key)
 	}
 
 	query := strings.TrimPrefix(key, foundName)
@@ -224,42 +892,251 @@ func (s *TFState) Lookup(key string) (*Object, error) {
 	}
 	if strings.HasPrefix(query, ".") || query == "" {
 		attr := &Object{noneNil(found.data, found.Attributes, found.AttributesFlat)}
-		return attr.Query(quoteJQQuery(query))
+		if mask != nil {
+			if found.sensitive {
+				attr.Value = *mask
+			} else {
+				attr.Value = maskSensitive(attr.Value, found.SensitiveAttributes, *mask)
+			}
+		}
+		q := quoteJQQuery(query)
+		res, err := attr.query(ctx, q, nil)
+		if errors.Is(err, ErrNotFound) {
+			return nil, s.notFound(key)
+		} else if err != nil {
+			return nil, errors.Wrap(err, key)
+		}
+		return res, nil
 	}
+	return nil, s.notFound(key)
+}
+
+// wildcardIndex is an index of all instances of a resource such as aws_instance.foo[*].id
+const wildcardIndex = "[*]"
 
-	return &Object{}, nil
+// lookupWildcard lookups the query for all instances of the resource address and returns the results as an array.
+// ok is false when the resource is not found.
+func (s *TFState) lookupWildcard(ctx context.Context, address, query string, mask *interface{}) (obj *Object, ok bool, err error) {
+	values := []interface{}{}
+	for _, r := range s.state.Resources {
+		if (r.Mode != "data" && r.Mode != "managed") || r.address(nil) != address {
+			continue
+		}
+		ok = true
+		for _, ins := range r.Instances {
+			if ins.Deposed != "" {
+				continue
+			}
+			o, err := s.lookup(ctx, r.address(ins.IndexKey)+query, mask)
+			if errors.Is(err, ErrNotFound) {
+				values = append(values, nil)
+				continue
+			} else if err != nil {
+				return nil, true, err
+			}
+			values = append(values, o.Value)
+		}
+	}
+	if !ok {
+		return nil, false, nil
+	}
+	return &Object{values}, true, nil
+}
+
+// Output represents an output of the root module in tfstate.
+type Output struct {
+	Sensitive bool        `json:"sensitive"`
+	Type      interface{} `json:"type"`
+	Value     interface{} `json:"value"`
+}
+
+// Object returns the value of the output as an Object.
+func (o Output) Object() *Object {
+	return &Object{o.Value}
+}
+
+// Outputs returns outputs of the root module in tfstate as `terraform output -json` does.
+func (s *TFState) Outputs() (map[string]Output, error) {
+	outputs := make(map[string]Output, len(s.state.Outputs))
+	for name, v := range s.state.Outputs {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to encode output %s", name)
+		}
+		var o Output
+		if err := json.Unmarshal(b, &o); err != nil {
+			return nil, errors.Wrapf(err, "invalid output %s", name)
+		}
+		outputs[name] = o
+	}
+	return outputs, nil
+}
+
+// Dump returns the whole tfstate decoded as a map, including resources and outputs.
+func (s *TFState) Dump() (map[string]interface{}, error) {
+	var m map[string]interface{}
+	if err := json.Unmarshal(s.raw, &m); err != nil {
+		return nil, errors.Wrap(err, "invalid json")
+	}
+	return m, nil
+}
+
+// Query queries the whole tfstate by go-jq
+func (s *TFState) Query(query string) (*Object, error) {
+	var v interface{}
+	if err := json.Unmarshal(s.raw, &v); err != nil {
+		return nil, errors.Wrap(err, "invalid json")
+	}
+	attr := &Object{v}
+	return attr.Query(query)
 }
 
 // query is passed to gojq.Compile() such as `.outputs.arn`.
 // If query contains the characters other than [jq's identifier-like characters](https://stedolan.github.io/jq/manual/#ObjectIdentifier-Index:.foo,.foo.bar),
 // we must quote them like `.outputs["repository-arn"]`.
 //
-// quoteJQQuery does it.
+// quoteJQQuery does it. Brackets such as `["foo.bar"]` and `[-1]` are kept as is.
 func quoteJQQuery(query string) string {
-	if !strings.Contains(query, "-") {
+	if !strings.ContainsAny(query, jqQuotedChars) {
 		return query
 	}
-	parts := strings.Split(query, ".")
 	var builder strings.Builder
-	for _, part := range parts {
-		// Split(".outputs", ".") -> {"", "outputs"}
-		if part == "" {
-			continue
+	for i := 0; i < len(query); {
+		switch query[i] {
+		case '.':
+			j := i + 1
+			for j < len(query) && query[j] != '.' && query[j] != '[' {
+				j++
+			}
+			// Split(".outputs", ".") -> {"", "outputs"}
+			if part := query[i+1 : j]; strings.ContainsAny(part, jqQuotedChars) {
+				if builder.Len() == 0 {
+					// ["foo-bar"] is an array, .["foo-bar"] is an index
+					builder.WriteByte('.')
+				}
+				builder.WriteString(`[`)
+				builder.WriteString(jqString(part))
+				builder.WriteString(`]`)
+			} else if part != "" || builder.Len() == 0 {
+				builder.WriteByte('.')
+				builder.WriteString(part)
+			}
+			i = j
+		case '[':
+			j := closingBracket(query, i)
+			builder.WriteString(query[i : j+1])
+			i = j + 1
+		default:
+			builder.WriteByte(query[i])
+			i++
+		}
+	}
+	return builder.String()
+}
+
+// jqQuotedChars are characters in keys which break the query unless quoted.
+// Others such as '|' and ' ' are left for jq expressions like `.tags | keys`.
+const jqQuotedChars = "-\"\\'/:@"
+
+// closingBracket returns the index of ']' which closes '[' at the start, with skipping quoted strings.
+// If not closed, returns the last index of s.
+func closingBracket(s string, start int) int {
+	depth := 0
+	inString := false
+	for i := start; i < len(s); i++ {
+		switch c := s[i]; {
+		case inString:
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				inString = false
+			}
+		case c == '"':
+			inString = true
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
 		}
-		if strings.Contains(part, "-") {
-			builder.WriteString(`["`)
-			builder.WriteString(part)
-			builder.WriteString(`"]`)
-		} else {
-			builder.WriteByte('.')
-			builder.WriteString(part)
+	}
+	return len(s) - 1
+}
+
+// canonicalIndexKey returns compact JSON of the index key without HTML escaping,
+// so index keys encoded differently (indented tuples, "\u0026" and so on) are looked up in the same form.
+func canonicalIndexKey(raw []byte) string {
+	var v interface{}
+	d := json.NewDecoder(bytes.NewReader(raw))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil || d.More() {
+		return string(raw)
+	}
+	var b bytes.Buffer
+	e := json.NewEncoder(&b)
+	e.SetEscapeHTML(false)
+	if err := e.Encode(v); err != nil {
+		return string(raw)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// canonicalIndexKeys replaces JSON values in brackets of the key such as ["a", 1] with canonicalIndexKey.
+// Others such as [*] and [1:3] are kept as is.
+func canonicalIndexKeys(key string) string {
+	if !strings.Contains(key, "[") {
+		return key
+	}
+	var builder strings.Builder
+	inString := false
+	for i := 0; i < len(key); i++ {
+		switch c := key[i]; {
+		case inString:
+			builder.WriteByte(c)
+			if c == '\\' && i+1 < len(key) {
+				i++
+				builder.WriteByte(key[i])
+			} else if c == '"' {
+				inString = false
+			}
+		case c == '"':
+			inString = true
+			builder.WriteByte(c)
+		case c == '[':
+			j := closingBracket(key, i)
+			if key[j] != ']' {
+				builder.WriteString(key[i:])
+				return builder.String()
+			}
+			builder.WriteByte('[')
+			builder.WriteString(canonicalIndexKey([]byte(key[i+1 : j])))
+			builder.WriteByte(']')
+			i = j
+		default:
+			builder.WriteByte(c)
 		}
 	}
 	return builder.String()
 }
 
+// jqString returns s as a string literal of jq
+func jqString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
 // List lists resource and output names in tfstate
 func (s *TFState) List() ([]string, error) {
+	return s.ListContext(context.Background())
+}
+
+// ListContext lists resource and output names in tfstate as List does, unless the context is done.
+func (s *TFState) ListContext(ctx context.Context) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	s.once.Do(s.scan)
 	names := make([]string, 0, len(s.scanned))
 	for key := range s.scanned {
@@ -269,10 +1146,151 @@ func (s *TFState) List() ([]string, error) {
 	return names, nil
 }
 
+// Flatten returns flattened keys and values of all resources and outputs in tfstate.
+func (s *TFState) Flatten() (map[string]string, error) {
+	return s.flattenState(nil), nil
+}
+
+// flattenState flattens all resources and outputs, and the sensitive values are replaced by the mask if not nil.
+func (s *TFState) flattenState(mask *interface{}) map[string]string {
+	s.once.Do(s.scan)
+	m := make(map[string]string)
+	for name, ins := range s.scanned {
+		v := noneNil(ins.data, ins.Attributes, ins.AttributesFlat)
+		if mask != nil {
+			if ins.sensitive {
+				v = *mask
+			} else {
+				v = maskSensitive(v, ins.SensitiveAttributes, *mask)
+			}
+		}
+		flatten(m, name, v)
+	}
+	return m
+}
+
+// ListByType lists resource names of the specified types in tfstate
+func (s *TFState) ListByType(types ...string) ([]string, error) {
+	s.once.Do(s.scan)
+	names := make([]string, 0)
+	for key, ins := range s.scanned {
+		for _, t := range types {
+			if ins.resourceType != "" && ins.resourceType == t {
+				names = append(names, key)
+				break
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// ListAddresses lists resource instance addresses in tfstate as `terraform state list` does.
+// If types are specified, only resources of the types are listed.
+func (s *TFState) ListAddresses(types ...string) ([]string, error) {
+	names := make([]string, 0, len(s.state.Resources))
+	for _, r := range s.state.Resources {
+		if r.Mode != "data" && r.Mode != "managed" {
+			continue
+		}
+		if len(types) > 0 && !containsString(types, r.Type) {
+			continue
+		}
+		for _, i := range r.Instances {
+			if i.Deposed != "" {
+				continue
+			}
+			names = append(names, r.address(i.IndexKey))
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// ListModules lists module paths in tfstate including the parents of nested modules.
+func (s *TFState) ListModules() ([]string, error) {
+	seen := make(map[string]bool)
+	names := make([]string, 0)
+	for _, r := range s.state.Resources {
+		for _, m := range modulePaths(r.Module) {
+			if !seen[m] {
+				seen[m] = true
+				names = append(names, m)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// modulePaths returns the module path and its parents.
+// e.g. module.a["x.y"].module.b -> [module.a["x.y"], module.a["x.y"].module.b]
+func modulePaths(module string) []string {
+	var paths []string
+	depth := 0
+	inString := false
+	for i := 0; i < len(module); i++ {
+		switch c := module[i]; {
+		case inString:
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				inString = false
+			}
+		case c == '"':
+			inString = true
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		case c == '.' && depth == 0 && strings.HasPrefix(module[i:], ".module.") && i > 0:
+			paths = append(paths, module[:i])
+		}
+	}
+	if module != "" {
+		paths = append(paths, module)
+	}
+	return paths
+}
+
+// address returns the resource instance address such as module.foo.data.aws_vpc.main["a"]
+func (r resource) address(indexKey json.RawMessage) string {
+	var b strings.Builder
+	if r.Module != "" {
+		b.WriteString(r.Module)
+		b.WriteByte('.')
+	}
+	if r.Mode == "data" {
+		b.WriteString("data.")
+	}
+	b.WriteString(r.Type)
+	b.WriteByte('.')
+	b.WriteString(r.Name)
+	if len(indexKey) > 0 {
+		b.WriteByte('[')
+		b.WriteString(canonicalIndexKey(indexKey))
+		b.WriteByte(']')
+	}
+	return b.String()
+}
+
+func containsString(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *TFState) scan() {
 	s.scanned = make(map[string]instance, len(s.state.Resources))
 	for key, value := range s.state.Outputs {
-		s.scanned["output."+key] = instance{data: outputValue(value)}
+		ins := instance{data: outputValue(value)}
+		if mv, ok := value.(map[string]interface{}); ok {
+			ins.sensitive = boolv(mv["sensitive"])
+		}
+		s.scanned["output."+key] = ins
 	}
 	for _, r := range s.state.Resources {
 		var module string
@@ -292,16 +1310,21 @@ func (s *TFState) scan() {
 						data[k] = outputValue(v)
 					}
 					key := module + fmt.Sprintf("%s%s.%s", prefix, r.Type, r.Name)
-					s.scanned[key] = instance{data: data}
+					s.scanned[key] = instance{data: data, resourceType: r.Type}
 				}
 			} else {
 				for _, i := range r.Instances {
 					ins := i
+					ins.resourceType = r.Type
 					var key string
 					if len(ins.IndexKey) == 0 {
 						key = module + fmt.Sprintf("%s%s.%s", prefix, r.Type, r.Name)
 					} else {
-						key = module + fmt.Sprintf("%s%s.%s[%s]", prefix, r.Type, r.Name, string(i.IndexKey))
+						key = module + fmt.Sprintf("%s%s.%s[%s]", prefix, r.Type, r.Name, canonicalIndexKey(i.IndexKey))
+					}
+					if ins.Deposed != "" {
+						s.scanDeposed(key, ins)
+						continue
 					}
 					s.scanned[key] = ins
 				}
@@ -310,6 +1333,22 @@ func (s *TFState) scan() {
 	}
 }
 
+// scanDeposed adds the deposed object of the instance to <address>.deposed keyed by the deposed key,
+// such as aws_instance.foo.deposed.deadbeef.id.
+func (s *TFState) scanDeposed(address string, ins instance) {
+	key := address + ".deposed"
+	d, ok := s.scanned[key]
+	if !ok {
+		d = instance{data: make(map[string]interface{}), resourceType: ins.resourceType}
+	}
+	d.data.(map[string]interface{})[ins.Deposed] = noneNil(ins.Attributes, ins.AttributesFlat)
+	for _, p := range ins.SensitiveAttributes {
+		step := sensitiveStep{Type: "get_attr", Value: json.RawMessage(jqString(ins.Deposed))}
+		d.SensitiveAttributes = append(d.SensitiveAttributes, append(sensitivePath{step}, p...))
+	}
+	s.scanned[key] = d
+}
+
 func noneNil(args ...interface{}) interface{} {
 	for _, v := range args {
 		if v != nil {
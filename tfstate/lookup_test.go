@@ -1,13 +1,19 @@
 package tfstate_test
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"sort"
+	"strings"
 	"testing"
+	"testing/fstest"
 
 	"github.com/fujiwara/tfstate-lookup/tfstate"
 	"github.com/google/go-cmp/cmp"
@@ -25,6 +31,7 @@ func init() {
 var TestNames = []string{
 	`output.bar`,
 	`output.foo`,
+	`output.secret`,
 	`data.aws_caller_identity.current`,
 	`aws_acm_certificate.main`,
 	`module.logs.aws_cloudwatch_log_group.main`,
@@ -187,8 +194,12 @@ var TestSuitesOK = []TestSuite{
 func testLookupState(t *testing.T, state *tfstate.TFState) {
 	for _, ts := range TestSuitesOK {
 		res, err := state.Lookup(ts.Key)
+		if ts.Result == nil && errors.Is(err, tfstate.ErrNotFound) {
+			continue
+		}
 		if err != nil {
 			t.Error(err)
+			continue
 		}
 		t.Log(ts.Key, res)
 		if diff := cmp.Diff(res.Value, ts.Result); diff != "" {
@@ -209,6 +220,41 @@ func TestLookupFile(t *testing.T) {
 	testLookupState(t, state)
 }
 
+func TestReadFS(t *testing.T) {
+	b, err := os.ReadFile("test/terraform.tfstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var workspace string
+	tfstate.RegisterBackend("readfs", func(ctx context.Context, config map[string]interface{}, ws string) (io.ReadCloser, error) {
+		workspace = ws
+		return io.NopCloser(bytes.NewReader(b)), nil
+	})
+	fsys := fstest.MapFS{
+		"local/terraform.tfstate":             {Data: b},
+		"remote/.terraform/terraform.tfstate": {Data: []byte(`{"version":3,"backend":{"type":"readfs","config":{}}}`)},
+		"remote/.terraform/environment":       {Data: []byte("dev")},
+	}
+	state, err := tfstate.ReadFS(fsys, "local/terraform.tfstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testLookupState(t, state)
+
+	state, err = tfstate.ReadFS(fsys, "remote/.terraform/terraform.tfstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testLookupState(t, state)
+	if workspace != "dev" {
+		t.Errorf("workspace must be read from the environment file in fsys, got %s", workspace)
+	}
+
+	if _, err := tfstate.ReadFS(fsys, "missing/terraform.tfstate"); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
 func TestLookupFileURL(t *testing.T) {
 	d, _ := os.Getwd()
 	state, err := tfstate.ReadURL(context.Background(), fmt.Sprintf("file://%s/test/terraform.tfstate", d))
@@ -249,3 +295,1183 @@ func TestList(t *testing.T) {
 		t.Errorf("unexpected list names %s", diff)
 	}
 }
+
+func TestQuery(t *testing.T) {
+	state, err := tfstate.ReadFile(context.Background(), "test/terraform.tfstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for query, expected := range map[string]interface{}{
+		`.version`:           float64(4),
+		`.outputs.foo.value`: "FOO",
+		`[.resources[] | .type] | unique | length`: 10,
+	} {
+		res, err := state.Query(query)
+		if err != nil {
+			t.Error(query, err)
+			continue
+		}
+		if diff := cmp.Diff(res.Value, expected); diff != "" {
+			t.Errorf("%s unexpected result %s", query, diff)
+		}
+	}
+	if _, err := state.Query(`.resources[] | select(.type == "xxx")`); err == nil {
+		t.Error("expected not found error")
+	}
+	if _, err := state.Query(`.outputs.xxx`); !errors.Is(err, tfstate.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestObjectExists(t *testing.T) {
+	obj := tfstate.Object{Value: map[string]interface{}{
+		"null": nil,
+		"list": []interface{}{"a", nil},
+		"tags": map[string]interface{}{"Name": "main"},
+	}}
+	for query, expected := range map[string]bool{
+		`.null`:         true,
+		`.list[1]`:      true,
+		`.list[-1]`:     true,
+		`.list[2]`:      false,
+		`.tags.Name`:    true,
+		`.tags.Env`:     false,
+		`.nothing`:      false,
+		`.nothing.deep`: false,
+		`.tags | keys`:  true,
+	} {
+		if e := obj.Exists(query); e != expected {
+			t.Errorf("unexpected Exists of %s: expected %v, got %v", query, expected, e)
+		}
+	}
+	res, err := obj.Query(`.null`)
+	if err != nil || res.Value != nil {
+		t.Errorf("unexpected result of null: %v %v", res, err)
+	}
+	if _, err := obj.Query(`.nothing`); !errors.Is(err, tfstate.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestListByType(t *testing.T) {
+	state, err := tfstate.ReadFile(context.Background(), "test/terraform.tfstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	names, err := state.ListByType("aws_iam_user", "aws_subnet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		`aws_iam_user.user["me"]`,
+		`aws_iam_user.users["foo.bar"]`,
+		`aws_iam_user.users["hoge.fuga"]`,
+		`module.subnets.aws_subnet.main[0]`,
+		`module.subnets.aws_subnet.main[1]`,
+	}
+	if diff := cmp.Diff(names, expected); diff != "" {
+		t.Errorf("unexpected list names %s", diff)
+	}
+}
+
+func TestListAddresses(t *testing.T) {
+	state, err := tfstate.ReadFile(context.Background(), "test/terraform.tfstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	names, err := state.ListAddresses()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var expected []string
+	for _, name := range TestNames {
+		if !strings.HasPrefix(name, "output.") {
+			expected = append(expected, name)
+		}
+	}
+	sort.Strings(expected)
+	if diff := cmp.Diff(names, expected); diff != "" {
+		t.Errorf("unexpected addresses %s", diff)
+	}
+
+	names, err = state.ListAddresses("aws_iam_role_policy_attachment")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(names, []string{`aws_iam_role_policy_attachment.ec2[0]`, `aws_iam_role_policy_attachment.ec2[1]`}); diff != "" {
+		t.Errorf("unexpected addresses %s", diff)
+	}
+}
+
+func TestFlatten(t *testing.T) {
+	state, err := tfstate.ReadFile(context.Background(), "test/terraform.tfstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := state.Flatten()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, expected := range map[string]string{
+		`output.foo`:    "FOO",
+		`output.bar[1]`: "B",
+		`module.logs.aws_cloudwatch_log_group.main["app"].retention_in_days`: "30",
+		`module.logs.aws_cloudwatch_log_group.main["app"].tags.env`:          "world",
+		`module.logs.aws_cloudwatch_log_group.main["app"].name_prefix`:       "null",
+		`aws_acm_certificate.main.subject_alternative_names[0]`:              "*.example.com",
+		`data.terraform_remote_state.hyphenated-id.outputs.repository-uri`:   "123456789012.dkr.ecr.ap-northeast-1.amazonaws.com/app",
+	} {
+		if m[key] != expected {
+			t.Errorf("unexpected value of %s: expected %s, got %s", key, expected, m[key])
+		}
+	}
+	// all flattened keys can be looked up
+	for key, value := range m {
+		obj, err := state.Lookup(key)
+		if err != nil {
+			t.Error(key, err)
+			continue
+		}
+		if obj.String() != value {
+			t.Errorf("unexpected lookup result of %s: expected %s, got %s", key, value, obj.String())
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	state, err := tfstate.ReadFile(context.Background(), "test/terraform.tfstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := tfstate.Read(context.Background(), strings.NewReader(`{
+  "version": 4,
+  "outputs": {
+    "foo": {"value": "OTHER", "type": "string"},
+    "other": {"value": "OTHER", "type": "string"}
+  },
+  "resources": [
+    {
+      "mode": "managed",
+      "type": "aws_acm_certificate",
+      "name": "main",
+      "instances": [{"attributes": {"validation_method": "EMAIL"}}]
+    },
+    {
+      "mode": "managed",
+      "type": "aws_vpc",
+      "name": "other",
+      "instances": [{"attributes": {"id": "vpc-other"}}]
+    }
+  ]
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	merged, conflicts, err := tfstate.Merge(state, other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(conflicts, []string{"aws_acm_certificate.main", "output.foo"}); diff != "" {
+		t.Errorf("unexpected conflicts %s", diff)
+	}
+	// the first state wins
+	testLookupState(t, merged)
+	for key, expected := range map[string]interface{}{
+		"output.other":     "OTHER",
+		"aws_vpc.other.id": "vpc-other",
+	} {
+		res, err := merged.Lookup(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(res.Value, expected); diff != "" {
+			t.Errorf("%s unexpected result %s", key, diff)
+		}
+	}
+	names, err := merged.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != len(TestNames)+2 {
+		t.Errorf("unexpected number of names %d", len(names))
+	}
+	// the raw documents are merged as is
+	for _, q := range []string{`.resources[0]`, `.outputs.foo`, `.terraform_version`, `.lineage`} {
+		orig, err := state.Query(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := merged.Query(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(res.Value, orig.Value); diff != "" {
+			t.Errorf("%s unexpected raw value %s", q, diff)
+		}
+	}
+	if res, err := merged.Query(`.resources[-1]`); err != nil || res.String() != `{"instances":[{"attributes":{"id":"vpc-other"}}],"mode":"managed","name":"other","type":"aws_vpc"}` {
+		t.Errorf("unexpected raw resource of the other state %v %v", res, err)
+	}
+}
+
+func TestListModules(t *testing.T) {
+	state, err := tfstate.ReadFile(context.Background(), "test/terraform.tfstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	names, err := state.ListModules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"module.example",
+		"module.logs",
+		"module.subnets",
+		"module.webapp",
+		"module.webapp.module.ecs_task_roles",
+	}
+	if diff := cmp.Diff(names, expected); diff != "" {
+		t.Errorf("unexpected modules %s", diff)
+	}
+}
+
+func TestModulePaths(t *testing.T) {
+	for module, expected := range map[string][]string{
+		"":                          nil,
+		"module.a":                  {"module.a"},
+		`module.a["x.module.y"]`:    {`module.a["x.module.y"]`},
+		`module.a[0].module.b["c"]`: {`module.a[0]`, `module.a[0].module.b["c"]`},
+		`module.a["\"]"].module.b`:  {`module.a["\"]"]`, `module.a["\"]"].module.b`},
+	} {
+		if diff := cmp.Diff(tfstate.ModulePaths(module), expected); diff != "" {
+			t.Errorf("unexpected module paths of %s %s", module, diff)
+		}
+	}
+}
+
+func TestOutputs(t *testing.T) {
+	state, err := tfstate.ReadFile(context.Background(), "test/terraform.tfstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputs, err := state.Outputs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]tfstate.Output{
+		"foo": {Value: "FOO", Type: "string"},
+		"bar": {
+			Value: []interface{}{"A", "B", "C"},
+			Type:  []interface{}{"tuple", []interface{}{"string", "string", "string"}},
+		},
+		"secret": {Value: "s3cr3t", Type: "string", Sensitive: true},
+	}
+	if diff := cmp.Diff(outputs, expected); diff != "" {
+		t.Errorf("unexpected outputs %s", diff)
+	}
+	if s := outputs["bar"].Object().String(); s != `["A","B","C"]` {
+		t.Errorf("unexpected output object %s", s)
+	}
+}
+
+func TestLookupAll(t *testing.T) {
+	state, err := tfstate.ReadFile(context.Background(), "test/terraform.tfstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := state.LookupAll([]string{"output.foo", "output.bar[0]", "output.missing", "aws_vpc.missing.id"})
+	var errs tfstate.LookupErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected LookupErrors, got %v", err)
+	}
+	if len(errs) != 2 || !errors.Is(errs["output.missing"], tfstate.ErrNotFound) || !errors.Is(errs["aws_vpc.missing.id"], tfstate.ErrNotFound) {
+		t.Errorf("unexpected errors %v", errs)
+	}
+	if len(results) != 2 || results["output.foo"].String() != "FOO" || results["output.bar[0]"].String() != "A" {
+		t.Errorf("unexpected results %v", results)
+	}
+
+	if _, err := state.LookupAll([]string{"output.foo"}); err != nil {
+		t.Errorf("unexpected error %s", err)
+	}
+}
+
+func TestVersion(t *testing.T) {
+	state, err := tfstate.ReadFile(context.Background(), "test/terraform.tfstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := state.FormatVersion(); v != 4 {
+		t.Errorf("unexpected format version %d", v)
+	}
+	if v := state.TerraformVersion(); v != "0.12.16" {
+		t.Errorf("unexpected terraform version %s", v)
+	}
+	if v := state.Serial(); v != 173 {
+		t.Errorf("unexpected serial %d", v)
+	}
+	if v := state.Lineage(); v != "054d7292-3d84-0584-4590-24d6f3b17399" {
+		t.Errorf("unexpected lineage %s", v)
+	}
+}
+
+func TestDump(t *testing.T) {
+	state, err := tfstate.ReadFile(context.Background(), "test/terraform.tfstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := state.Dump()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := m["version"]; v != float64(4) {
+		t.Errorf("unexpected version %v", v)
+	}
+	if _, ok := m["resources"].([]interface{}); !ok {
+		t.Errorf("resources are not dumped %#v", m["resources"])
+	}
+	outputs, _ := m["outputs"].(map[string]interface{})
+	if diff := cmp.Diff(outputs["foo"], map[string]interface{}{"value": "FOO", "type": "string"}); diff != "" {
+		t.Errorf("unexpected output %s", diff)
+	}
+}
+
+func TestLookupMasked(t *testing.T) {
+	state, err := tfstate.ReadFile(context.Background(), "test/terraform.tfstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, expected := range map[string]interface{}{
+		`output.secret`: "***",
+		`output.foo`:    "FOO",
+		`module.logs.aws_cloudwatch_log_group.main["web"].tags.env`:   "***",
+		`module.logs.aws_cloudwatch_log_group.main["web"].kms_key_id`: "***",
+		`module.logs.aws_cloudwatch_log_group.main["web"].name`:       "/main/web",
+		`module.logs.aws_cloudwatch_log_group.main["app"].tags.env`:   "world",
+	} {
+		res, err := state.LookupMasked(key, "***")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(res.Value, expected); diff != "" {
+			t.Errorf("%s unexpected result %s", key, diff)
+		}
+	}
+	// the state is not modified
+	res, err := state.Lookup(`module.logs.aws_cloudwatch_log_group.main["web"].tags.env`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Value != "world" {
+		t.Errorf("unexpected result %s", res.Value)
+	}
+}
+
+var TestAddressSuites = []TestSuite{
+	{
+		Key:    `module.a.module.b.aws_instance.x.id`,
+		Result: "i-ab",
+	},
+	{
+		Key:    `module.a.module.b.module.c.aws_instance.x.id`,
+		Result: "i-abc",
+	},
+	{
+		Key:    `module.a["0"].aws_instance.x.id`,
+		Result: "i-a0",
+	},
+	{
+		Key:    `module.a[1].module.b.aws_instance.x.id`,
+		Result: "i-a1b",
+	},
+	{
+		Key:    `module.a[0].aws_instance.x.id`,
+		Result: nil,
+	},
+	{
+		Key:    `module.b.aws_instance.x.id`,
+		Result: nil,
+	},
+	// count
+	{
+		Key:    `aws_instance.count[0].id`,
+		Result: "i-count0",
+	},
+	{
+		Key:    `aws_instance.count[1].id`,
+		Result: "i-count1",
+	},
+	{
+		Key:    `aws_instance.count["0"].id`,
+		Result: nil,
+	},
+	// for_each
+	{
+		Key:    `aws_instance.each["0"].id`,
+		Result: "i-each0",
+	},
+	{
+		Key:    `aws_instance.each["a"].id`,
+		Result: "i-eacha",
+	},
+	{
+		Key:    `aws_instance.each[0].id`,
+		Result: nil,
+	},
+	// for_each keys with dots and hyphens
+	{
+		Key:    `aws_s3_bucket.logs["my.bucket.name"].id`,
+		Result: "my.bucket.name",
+	},
+	{
+		Key:    `aws_s3_bucket.logs["my.bucket.name"].tags["my-tag.x"]`,
+		Result: "v",
+	},
+	{
+		Key:    `aws_s3_bucket.logs["my.bucket.name"].tags.Name`,
+		Result: "logs",
+	},
+	{
+		Key:    `aws_s3_bucket.logs["my-bucket"].tags.Name`,
+		Result: "logs2",
+	},
+	{
+		Key:    `aws_s3_bucket.logs["my.bucket.name"].tags.my-tag`,
+		Result: nil,
+	},
+	// data sources in modules
+	{
+		Key:    `module.network.data.aws_subnet.selected.id`,
+		Result: "subnet-selected",
+	},
+	{
+		Key:    `module.network.data.aws_subnet.each["a"].id`,
+		Result: "subnet-each-a",
+	},
+	{
+		Key:    `data.aws_subnet.selected.id`,
+		Result: nil,
+	},
+	{
+		Key:    `module.network.aws_subnet.selected.id`,
+		Result: nil,
+	},
+	// all instances
+	{
+		Key:    `aws_instance.count[*].id`,
+		Result: []interface{}{"i-count0", "i-count1"},
+	},
+	{
+		Key:    `aws_instance.each[*].id`,
+		Result: []interface{}{"i-each0", "i-eacha"},
+	},
+	{
+		Key:    `module.network.data.aws_subnet.each[*].id`,
+		Result: []interface{}{"subnet-each-a"},
+	},
+	{
+		Key:    `aws_s3_bucket.logs[*].tags.Name`,
+		Result: []interface{}{"logs", "logs2"},
+	},
+	{
+		Key:    `module.network.data.aws_subnet.selected[*].id`,
+		Result: []interface{}{"subnet-selected"},
+	},
+	{
+		Key:    `aws_instance.nothing[*].id`,
+		Result: nil,
+	},
+	// quotes and backslashes
+	{
+		Key:    `aws_iam_user.quoted["foo\"bar\\baz"].id`,
+		Result: "quoted",
+	},
+	{
+		Key:    `aws_iam_user.quoted["foo\"bar\\baz"].tags.a"b`,
+		Result: "q",
+	},
+	{
+		Key:    `aws_iam_user.quoted["foo\"bar\\baz"].tags["c\\d"]`,
+		Result: "b",
+	},
+	{
+		Key:    `aws_iam_user.quoted["foo\"bar\\baz"].tags.e:f`,
+		Result: "c",
+	},
+	{
+		Key:    `aws_iam_user.quoted["foo\"bar\\baz"].tags | keys | length`,
+		Result: 3,
+	},
+	// negative indices
+	{
+		Key:    `aws_lb.main.subnet_mapping[-1].subnet_id`,
+		Result: "subnet-c",
+	},
+	{
+		Key:    `aws_lb.main.subnet_mapping[-2].subnet_id`,
+		Result: "subnet-b",
+	},
+	{
+		Key:    `aws_lb.main.subnet_mapping[-4].subnet_id`,
+		Result: nil,
+	},
+	{
+		Key:    `output.vpc.subnet-ids[-1]`,
+		Result: "subnet-b",
+	},
+	// composite and boolean index keys
+	{
+		Key:    `aws_instance.tuple[["a",1]].id`,
+		Result: "i-tuple",
+	},
+	{
+		Key:    `aws_instance.tuple[[ "a", 1 ]].id`,
+		Result: "i-tuple",
+	},
+	{
+		Key:    `aws_instance.tuple[["a","1"]].id`,
+		Result: nil,
+	},
+	{
+		Key:    `aws_instance.bool[true].id`,
+		Result: "i-true",
+	},
+	{
+		Key:    `aws_instance.bool["true"].id`,
+		Result: nil,
+	},
+	{
+		Key:    `aws_instance.amp["a&b"].id`,
+		Result: "i-amp",
+	},
+	{
+		Key:    `aws_instance.amp["a\u0026b"].id`,
+		Result: "i-amp",
+	},
+	// deposed objects
+	{
+		Key:    `aws_instance.replaced.id`,
+		Result: "i-new",
+	},
+	{
+		Key:    `aws_instance.replaced.deposed.deadbeef.id`,
+		Result: "i-old",
+	},
+	{
+		Key:    `aws_instance.replaced.deposed`,
+		Result: map[string]interface{}{"deadbeef": map[string]interface{}{"id": "i-old", "password": "secret"}},
+	},
+	{
+		Key:    `aws_instance.replaced[*].id`,
+		Result: []interface{}{"i-new"},
+	},
+	// root and module resources of the same name
+	{
+		Key:    `aws_instance.foo.id`,
+		Result: "i-root-foo",
+	},
+	{
+		Key:    `module.foo.aws_instance.foo.id`,
+		Result: "i-module-foo",
+	},
+	{
+		Key:    `module.aws_instance.aws_instance.foo.id`,
+		Result: "i-module-aws-instance-foo",
+	},
+	{
+		Key:    `module.foo.id`,
+		Result: nil,
+	},
+	{
+		Key:    `module.a.aws_instance.x.id`,
+		Result: nil,
+	},
+	{
+		Key:    `aws_instance.foo[*].id`,
+		Result: []interface{}{"i-root-foo"},
+	},
+	// outputs
+	{
+		Key:    `output.vpc.id`,
+		Result: "vpc-1",
+	},
+	{
+		Key:    `output.vpc.subnet-ids[1]`,
+		Result: "subnet-b",
+	},
+	{
+		Key:    `output.vpc.value`,
+		Result: nil,
+	},
+}
+
+func TestQuoteJQQuery(t *testing.T) {
+	for query, expected := range map[string]string{
+		``:                           ``,
+		`.outputs.arn`:               `.outputs.arn`,
+		`.outputs.repository-uri`:    `.outputs["repository-uri"]`,
+		`.tags["my-tag.x"]`:          `.tags["my-tag.x"]`,
+		`.foo-bar[0].baz`:            `.["foo-bar"][0].baz`,
+		`.tags["a]-b"].foo-bar`:      `.tags["a]-b"]["foo-bar"]`,
+		`.list[-1].name`:             `.list[-1].name`,
+		`.outputs.repository-uri[0]`: `.outputs["repository-uri"][0]`,
+		`.tags.a"b`:                  `.tags["a\"b"]`,
+		`.tags.c\d.e:f`:              `.tags["c\\d"]["e:f"]`,
+		`.tags | keys`:               `.tags | keys`,
+		`.["a\"b"]`:                  `.["a\"b"]`,
+	} {
+		if q := tfstate.QuoteJQQuery(query); q != expected {
+			t.Errorf("unexpected quoted query of %s: expected %s, got %s", query, expected, q)
+		}
+	}
+}
+
+func TestCanonicalIndexKeys(t *testing.T) {
+	for key, expected := range map[string]string{
+		`aws_instance.foo.id`:                `aws_instance.foo.id`,
+		`aws_instance.foo[[ "a", 1 ]].id`:    `aws_instance.foo[["a",1]].id`,
+		`aws_instance.foo["a\u0026b"].id`:    `aws_instance.foo["a&b"].id`,
+		`aws_instance.foo[0].list[ -1 ]`:     `aws_instance.foo[0].list[-1]`,
+		`aws_instance.foo[*].list[1:3]`:      `aws_instance.foo[*].list[1:3]`,
+		`aws_instance.foo.tags | .["[ 1 ]"]`: `aws_instance.foo.tags | .["[ 1 ]"]`,
+		`aws_instance.foo.list[]`:            `aws_instance.foo.list[]`,
+		`aws_instance.foo[`:                  `aws_instance.foo[`,
+	} {
+		if k := tfstate.CanonicalIndexKeys(key); k != expected {
+			t.Errorf("unexpected canonical key of %s: expected %s, got %s", key, expected, k)
+		}
+	}
+}
+
+func TestLookupAddresses(t *testing.T) {
+	state, err := tfstate.ReadFile(context.Background(), "test/addresses.tfstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ts := range TestAddressSuites {
+		res, err := state.Lookup(ts.Key)
+		if ts.Result == nil && errors.Is(err, tfstate.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			t.Error(ts.Key, err)
+			continue
+		}
+		if diff := cmp.Diff(res.Value, ts.Result); diff != "" {
+			t.Errorf("%s unexpected result %s", ts.Key, diff)
+		}
+	}
+}
+
+func TestFilterProvider(t *testing.T) {
+	state, err := tfstate.ReadFile(context.Background(), "test/addresses.tfstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	filtered, err := state.FilterProvider("us_east_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	names, err := filtered.ListAddresses()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(names, []string{"aws_instance.aliased"}); diff != "" {
+		t.Errorf("unexpected addresses %s", diff)
+	}
+	for key, expected := range map[string]interface{}{
+		`aws_instance.aliased.id`:  "i-aliased",
+		`aws_instance.count[0].id`: nil,
+		`output.vpc.id`:            "vpc-1",
+	} {
+		res, err := filtered.Lookup(key)
+		if expected == nil && errors.Is(err, tfstate.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			t.Error(key, err)
+			continue
+		}
+		if diff := cmp.Diff(res.Value, expected); diff != "" {
+			t.Errorf("%s unexpected result %s", key, diff)
+		}
+	}
+	// the raw resources are kept as is
+	orig, err := state.Query(`[.resources[] | select(.name == "aliased")]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := filtered.Query(`.resources`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(res.Value, orig.Value); diff != "" {
+		t.Errorf("unexpected raw resources %s", diff)
+	}
+	if res, err := filtered.Query(`.lineage`); err != nil || res.Value == nil {
+		t.Errorf("top-level fields must be kept %v %v", res, err)
+	}
+	// the original state is not changed
+	if res, err := state.Lookup(`aws_instance.count[0].id`); err != nil || res.Value != "i-count0" {
+		t.Errorf("unexpected result of the original state %v %v", res, err)
+	}
+}
+
+func TestLookupCanonicalAddresses(t *testing.T) {
+	// addresses copied from terraform plan output
+	state, err := tfstate.ReadFile(context.Background(), "test/addresses.tfstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for addr, id := range map[string]string{
+		`module.a.module.b.aws_instance.x`:          "i-ab",
+		`module.a.module.b.module.c.aws_instance.x`: "i-abc",
+		`module.a["0"].aws_instance.x`:              "i-a0",
+		`module.a[1].module.b.aws_instance.x`:       "i-a1b",
+		`aws_instance.count[1]`:                     "i-count1",
+		`aws_instance.each["a"]`:                    "i-eacha",
+		`aws_s3_bucket.logs["my.bucket.name"]`:      "my.bucket.name",
+		`module.network.data.aws_subnet.each["a"]`:  "subnet-each-a",
+		` module.network.data.aws_subnet.selected`:  "subnet-selected",
+		"aws_instance.aliased\n":                    "i-aliased",
+	} {
+		res, err := state.Lookup(addr)
+		if err != nil {
+			t.Error(addr, err)
+			continue
+		}
+		if m, ok := res.Value.(map[string]interface{}); !ok || m["id"] != id {
+			t.Errorf("%s unexpected result %v", addr, res.Value)
+		}
+	}
+
+	for _, file := range []string{"test/terraform.tfstate", "test/addresses.tfstate"} {
+		state, err := tfstate.ReadFile(context.Background(), file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// addresses listed as terraform state list does can be looked up as is
+		addrs, err := state.ListAddresses()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, addr := range addrs {
+			res, err := state.Lookup(addr)
+			if err != nil {
+				t.Error(file, addr, err)
+				continue
+			}
+			if res.Value == nil {
+				t.Errorf("%s: %s is not found", file, addr)
+			}
+		}
+	}
+}
+
+func TestObjectPaths(t *testing.T) {
+	obj := tfstate.Object{Value: map[string]interface{}{
+		"id":      "x",
+		"list":    []interface{}{"a", map[string]interface{}{"b": 1}},
+		"tags":    map[string]interface{}{"Name": "main", "my.tag": "v"},
+		"empty":   map[string]interface{}{},
+		"enabled": true,
+	}}
+	expected := []string{
+		`.empty`,
+		`.enabled`,
+		`.id`,
+		`.list[0]`,
+		`.list[1].b`,
+		`.tags.Name`,
+		`.tags["my.tag"]`,
+	}
+	if diff := cmp.Diff(obj.Paths(), expected); diff != "" {
+		t.Errorf("unexpected paths %s", diff)
+	}
+	if paths := (tfstate.Object{Value: "scalar"}).Paths(); len(paths) != 0 {
+		t.Errorf("unexpected paths of a scalar %v", paths)
+	}
+}
+
+func TestLookupNotFound(t *testing.T) {
+	state, err := tfstate.ReadFile(context.Background(), "test/terraform.tfstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{
+		`aws_vpc.nothing.id`,
+		`output.baz`,
+		`aws_acm_certificate.main.nothing`,
+		`aws_acm_certificate.main.domain_validation_options[9]`,
+		`aws_iam_role_policy_attachment.ec2[2].id`,
+	} {
+		if _, err := state.Lookup(key); !errors.Is(err, tfstate.ErrNotFound) {
+			t.Errorf("%s: expected ErrNotFound, got %v", key, err)
+		}
+	}
+	// existing attributes of null are found
+	res, err := state.Lookup(`aws_acm_certificate.main.certificate_body`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Value != nil {
+		t.Errorf("unexpected result %v", res.Value)
+	}
+}
+
+func TestLookupSuggestion(t *testing.T) {
+	state, err := tfstate.ReadFile(context.Background(), "test/terraform.tfstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, expected := range map[string]string{
+		`aws_acm_certificat.main.arn`:                      `aws_acm_certificate.main.arn`,
+		`module.logs.aws_cloudwatch_log_group.mian["app"]`: `module.logs.aws_cloudwatch_log_group.main["app"]`,
+		`output.fo`:                      `output.foo`,
+		`output.bra[1]`:                  `output.bar[1]`,
+		`aws_iam_user.user["mee"].arn`:   `aws_iam_user.user["me"].arn`,
+		`google_compute_instance.foo.id`: ``,
+		`aws_acm_certificate.main2.arn`:  `aws_acm_certificate.main.arn`,
+		`output.fooo`:                    `output.foo`,
+		`aws_acm_certificate.main.arnn`:  `aws_acm_certificate.main.arn`,
+	} {
+		_, err := state.Lookup(key)
+		if !errors.Is(err, tfstate.ErrNotFound) {
+			t.Errorf("%s: expected ErrNotFound, got %v", key, err)
+			continue
+		}
+		var nf *tfstate.NotFoundError
+		if !errors.As(err, &nf) {
+			t.Errorf("%s: expected NotFoundError, got %v", key, err)
+			continue
+		}
+		if nf.Suggestion() != expected {
+			t.Errorf("%s: unexpected suggestion %s, expected %s", key, nf.Suggestion(), expected)
+		}
+	}
+}
+
+func TestLookupMaskedDeposed(t *testing.T) {
+	state, err := tfstate.ReadFile(context.Background(), "test/addresses.tfstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := state.LookupMasked(`aws_instance.replaced.deposed.deadbeef.password`, "***")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Value != "***" {
+		t.Errorf("unexpected result %v", res.Value)
+	}
+}
+
+func TestSensitiveAttributes(t *testing.T) {
+	state, err := tfstate.ReadFile(context.Background(), "test/terraform.tfstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for addr, expected := range map[string][]string{
+		`module.logs.aws_cloudwatch_log_group.main["web"]`: {".tags.env", ".kms_key_id"},
+		`module.logs.aws_cloudwatch_log_group.main["app"]`: {},
+		`output.secret`: {"."},
+		`output.foo`:    {},
+	} {
+		paths, err := state.SensitiveAttributes(addr)
+		if err != nil {
+			t.Error(addr, err)
+			continue
+		}
+		if diff := cmp.Diff(paths, expected); diff != "" {
+			t.Errorf("%s unexpected paths %s", addr, diff)
+		}
+	}
+	if _, err := state.SensitiveAttributes(`aws_vpc.nothing`); !errors.Is(err, tfstate.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	state, err = tfstate.ReadFile(context.Background(), "test/addresses.tfstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths, err := state.SensitiveAttributes(`aws_instance.replaced.deposed`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(paths, []string{".deadbeef.password"}); diff != "" {
+		t.Errorf("unexpected paths of deposed objects %s", diff)
+	}
+}
+
+func TestObjectBytesIndent(t *testing.T) {
+	for _, ts := range []struct {
+		value    interface{}
+		expected string
+	}{
+		{"str", "str"},
+		{float64(1), "1"},
+		{nil, "null"},
+		{[]interface{}{"a"}, "[\n  \"a\"\n]"},
+		{map[string]interface{}{"a": map[string]interface{}{"b": true}}, "{\n  \"a\": {\n    \"b\": true\n  }\n}"},
+	} {
+		if b := (tfstate.Object{Value: ts.value}).BytesIndent("", "  "); string(b) != ts.expected {
+			t.Errorf("unexpected bytes of %v: expected %s, got %s", ts.value, ts.expected, string(b))
+		}
+	}
+}
+
+func TestObjectAccessors(t *testing.T) {
+	for _, v := range []interface{}{float64(3), 3, "3"} {
+		if i, err := (tfstate.Object{Value: v}).Int(); err != nil || i != 3 {
+			t.Errorf("unexpected Int of %#v: %d %v", v, i, err)
+		}
+		if f, err := (tfstate.Object{Value: v}).Float(); err != nil || f != 3 {
+			t.Errorf("unexpected Float of %#v: %f %v", v, f, err)
+		}
+	}
+	for _, v := range []interface{}{1.5, "x", true, nil} {
+		if _, err := (tfstate.Object{Value: v}).Int(); err == nil {
+			t.Errorf("Int of %#v must be an error", v)
+		}
+	}
+	for _, v := range []interface{}{true, "true"} {
+		if b, err := (tfstate.Object{Value: v}).Bool(); err != nil || !b {
+			t.Errorf("unexpected Bool of %#v: %v %v", v, b, err)
+		}
+	}
+	if _, err := (tfstate.Object{Value: float64(1)}).Bool(); err == nil {
+		t.Error("Bool of a number must be an error")
+	}
+
+	state, err := tfstate.ReadFile(context.Background(), "test/terraform.tfstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj, err := state.Lookup(`output.bar`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	objs, err := obj.Slice()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objs) != 3 || objs[1].String() != "B" {
+		t.Errorf("unexpected Slice %v", objs)
+	}
+	if _, err := (tfstate.Object{Value: "x"}).Slice(); err == nil {
+		t.Error("Slice of a string must be an error")
+	}
+}
+
+func TestObjectMarshalJSON(t *testing.T) {
+	v := struct {
+		ID   tfstate.Object   `json:"id"`
+		Tags *tfstate.Object  `json:"tags"`
+		List []tfstate.Object `json:"list"`
+	}{
+		ID:   tfstate.Object{Value: "x"},
+		Tags: &tfstate.Object{Value: map[string]interface{}{"Name": "main"}},
+		List: []tfstate.Object{{Value: float64(1)}, {}},
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{"id":"x","tags":{"Name":"main"},"list":[1,null]}`; string(b) != expected {
+		t.Errorf("unexpected JSON: expected %s, got %s", expected, string(b))
+	}
+}
+
+func TestObjectEach(t *testing.T) {
+	obj := tfstate.Object{Value: []interface{}{"A", "B", "C"}}
+	if obj.Len() != 3 {
+		t.Errorf("unexpected Len %d", obj.Len())
+	}
+	var values []string
+	err := obj.Each(func(i int, o tfstate.Object) error {
+		values = append(values, fmt.Sprintf("%d:%s", i, o.String()))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(values, []string{"0:A", "1:B", "2:C"}); diff != "" {
+		t.Errorf("unexpected elements %s", diff)
+	}
+
+	stop := errors.New("stop")
+	n := 0
+	if err := obj.Each(func(i int, o tfstate.Object) error {
+		n++
+		return stop
+	}); err != stop || n != 1 {
+		t.Errorf("Each must stop at the first error: %v %d", err, n)
+	}
+
+	scalar := tfstate.Object{Value: "x"}
+	if scalar.Len() != 0 {
+		t.Errorf("unexpected Len of a string %d", scalar.Len())
+	}
+	if err := scalar.Each(func(int, tfstate.Object) error { return nil }); err == nil {
+		t.Error("Each of a string must be an error")
+	}
+}
+
+func TestObjectMap(t *testing.T) {
+	state, err := tfstate.ReadFile(context.Background(), "test/terraform.tfstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj, err := state.Lookup(`aws_iam_user.user["me"]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := obj.Map()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m["name"].String() != "me" {
+		t.Errorf("unexpected name %s", m["name"].String())
+	}
+	if _, err := (tfstate.Object{Value: []interface{}{}}).Map(); err == nil {
+		t.Error("Map of a list must be an error")
+	}
+}
+
+func TestObjectYAML(t *testing.T) {
+	obj := tfstate.Object{Value: map[string]interface{}{
+		"id":   "x",
+		"list": []interface{}{"a", float64(1)},
+		"tags": map[string]interface{}{"Name": "main"},
+	}}
+	b, err := obj.YAML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "id: x\nlist:\n  - a\n  - 1\ntags:\n  Name: main\n"
+	if string(b) != expected {
+		t.Errorf("unexpected YAML: expected %q, got %q", expected, string(b))
+	}
+}
+
+func TestObjectQueryWithVars(t *testing.T) {
+	state, err := tfstate.ReadFile(context.Background(), "test/terraform.tfstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	whole, err := state.Query(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := whole.QueryWithVars(
+		`.resources[] | select(.type == $type and .name == $name) | .instances[0].attributes.name`,
+		map[string]interface{}{"type": "aws_iam_user", "$name": "user"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Value != "me" {
+		t.Errorf("unexpected result %v", res.Value)
+	}
+	// values are not interpreted as jq
+	res, err = whole.QueryWithVars(`.outputs[$key]`, map[string]interface{}{"key": `foo"] | .bar`})
+	if !errors.Is(err, tfstate.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v %v", res, err)
+	}
+	if _, err := whole.QueryWithVars(`$undefined`, nil); err == nil {
+		t.Error("undefined variables must be an error")
+	}
+}
+
+func TestObjectKeys(t *testing.T) {
+	obj := tfstate.Object{Value: map[string]interface{}{"b": 1, "a": 2, "c": nil}}
+	keys, err := obj.Keys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(keys, []string{"a", "b", "c"}); diff != "" {
+		t.Errorf("unexpected keys %s", diff)
+	}
+	if _, err := (tfstate.Object{Value: "x"}).Keys(); err == nil {
+		t.Error("Keys of a string must be an error")
+	}
+}
+
+func TestObjectGet(t *testing.T) {
+	obj := tfstate.Object{Value: map[string]interface{}{
+		"list":   []interface{}{"a", map[string]interface{}{"b": "c"}},
+		"tags":   map[string]interface{}{"my.tag": "v", `a"b`: "q"},
+		"null":   nil,
+		"string": "s",
+	}}
+	for _, ts := range []struct {
+		path     []string
+		expected interface{}
+	}{
+		{[]string{"list", "0"}, "a"},
+		{[]string{"list", "1", "b"}, "c"},
+		{[]string{"list", "-1", "b"}, "c"},
+		{[]string{"tags", "my.tag"}, "v"},
+		{[]string{"tags", `a"b`}, "q"},
+		{[]string{"null"}, nil},
+		{nil, obj.Value},
+	} {
+		res, err := obj.Get(ts.path...)
+		if err != nil {
+			t.Error(ts.path, err)
+			continue
+		}
+		if diff := cmp.Diff(res.Value, ts.expected); diff != "" {
+			t.Errorf("%v unexpected result %s", ts.path, diff)
+		}
+	}
+	for _, path := range [][]string{{"nothing"}, {"list", "2"}, {"list", "-3"}, {"tags", "Name"}} {
+		if _, err := obj.Get(path...); !errors.Is(err, tfstate.ErrNotFound) {
+			t.Errorf("%v: expected ErrNotFound, got %v", path, err)
+		}
+	}
+	for _, path := range [][]string{{"list", "x"}, {"string", "x"}} {
+		if _, err := obj.Get(path...); err == nil || errors.Is(err, tfstate.ErrNotFound) {
+			t.Errorf("%v: expected an error, got %v", path, err)
+		}
+	}
+}
+
+func TestLookupContext(t *testing.T) {
+	state, err := tfstate.ReadFile(context.Background(), "test/terraform.tfstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := state.LookupContext(context.Background(), `output.foo`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Value != "FOO" {
+		t.Errorf("unexpected result %v", res.Value)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, key := range []string{`output.foo`, `module.subnets.aws_subnet.main[*].id`} {
+		if _, err := state.LookupContext(ctx, key); !errors.Is(err, context.Canceled) {
+			t.Errorf("%s: expected context.Canceled, got %v", key, err)
+		}
+	}
+}
+
+func TestListContext(t *testing.T) {
+	state, err := tfstate.ReadFile(context.Background(), "test/terraform.tfstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	names, err := state.ListContext(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(names, TestNames); diff != "" {
+		t.Errorf("unexpected names %s", diff)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := state.ListContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
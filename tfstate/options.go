@@ -0,0 +1,114 @@
+package tfstate
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// ReadOption is an option for ReadWithOptions and ReadFileWithOptions.
+type ReadOption func(*readOptions)
+
+type readOptions struct {
+	ctx       context.Context
+	workspace string
+	timeout   time.Duration
+	cache     *cacheOption
+
+	strictWorkspace bool
+
+	credentialProvider CredentialProvider
+	logger             Logger
+}
+
+// WithContext sets the context to read tfstate. The default is context.Background().
+func WithContext(ctx context.Context) ReadOption {
+	return func(o *readOptions) {
+		o.ctx = ctx
+	}
+}
+
+// WithWorkspace sets the workspace.
+// Without it, the default workspace is used (ReadFileWithOptions reads the environment file in the same directory).
+func WithWorkspace(ws string) ReadOption {
+	return func(o *readOptions) {
+		o.workspace = ws
+	}
+}
+
+// WithTimeout sets the timeout for reading tfstate including the remote state. Zero means no timeout.
+func WithTimeout(d time.Duration) ReadOption {
+	return func(o *readOptions) {
+		o.timeout = d
+	}
+}
+
+// WithCacheDir caches remote states in the directory for the ttl instead of TFSTATE_CACHE_DIR and TFSTATE_CACHE_TTL.
+// An empty dir disables the cache.
+func WithCacheDir(dir string, ttl time.Duration) ReadOption {
+	return func(o *readOptions) {
+		o.cache = &cacheOption{dir: dir, ttl: ttl}
+	}
+}
+
+// WithStrictWorkspace makes a missing or empty environment file an error instead of using the default workspace.
+// It takes effect only when the workspace is not specified by WithWorkspace.
+func WithStrictWorkspace(strict bool) ReadOption {
+	return func(o *readOptions) {
+		o.strictWorkspace = strict
+	}
+}
+
+// WithCredentialProvider sets the provider of credentials for the s3, gcs and azurerm backends.
+func WithCredentialProvider(p CredentialProvider) ReadOption {
+	return func(o *readOptions) {
+		o.credentialProvider = p
+	}
+}
+
+// WithLogger sets the logger for debug messages about the backend and the remote fetch. The default logger discards them.
+func WithLogger(l Logger) ReadOption {
+	return func(o *readOptions) {
+		o.logger = l
+	}
+}
+
+func newReadOptions(opts []ReadOption) *readOptions {
+	o := &readOptions{ctx: context.Background()}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// context returns the context with the timeout if specified.
+func (o *readOptions) context() (context.Context, context.CancelFunc) {
+	if o.timeout > 0 {
+		return context.WithTimeout(o.ctx, o.timeout)
+	}
+	return context.WithCancel(o.ctx)
+}
+
+// cacheOption returns the cache option set by WithCacheDir, or from the environment variables.
+func (o *readOptions) cacheOption() (cacheOption, error) {
+	if o.cache != nil {
+		return *o.cache, nil
+	}
+	return cacheOptionFromEnv()
+}
+
+// ReadWithOptions reads a tfstate from io.Reader with the options.
+func ReadWithOptions(src io.Reader, opts ...ReadOption) (*TFState, error) {
+	o := newReadOptions(opts)
+	ctx, cancel := o.context()
+	defer cancel()
+	return read(ctx, src, o)
+}
+
+// ReadFileWithOptions reads terraform.tfstate from the file with the options.
+func ReadFileWithOptions(file string, opts ...ReadOption) (*TFState, error) {
+	o := newReadOptions(opts)
+	ctx, cancel := o.context()
+	defer cancel()
+	return readFile(ctx, file, o)
+}
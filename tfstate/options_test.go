@@ -0,0 +1,160 @@
+package tfstate_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fujiwara/tfstate-lookup/tfstate"
+)
+
+func TestReadFileWithOptionsCacheDir(t *testing.T) {
+	var count int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count++
+		http.ServeFile(w, r, "test/terraform.tfstate")
+	}))
+	defer ts.Close()
+	t.Setenv("TFSTATE_CACHE_DIR", "")
+
+	dir := t.TempDir()
+	file := writeBackendState(t, "http", map[string]interface{}{
+		"address": ts.URL + "/state",
+	}, "")
+	for i := 0; i < 2; i++ {
+		state, err := tfstate.ReadFileWithOptions(file, tfstate.WithCacheDir(dir, time.Minute))
+		if err != nil {
+			t.Fatal(err)
+		}
+		testLookupState(t, state)
+	}
+	if count != 1 {
+		t.Errorf("remote state must be fetched once, but fetched %d times", count)
+	}
+	if caches, _ := filepath.Glob(filepath.Join(dir, "*.tfstate")); len(caches) != 1 {
+		t.Errorf("unexpected cache files %v", caches)
+	}
+}
+
+func TestReadFileWithOptionsWorkspace(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "minio")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "minio123")
+	t.Setenv("AWS_CONFIG_FILE", os.DevNull)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", os.DevNull)
+	t.Setenv("AWS_PROFILE", "")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/mybucket/env:/dev/path/to/terraform.tfstate" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeFile(w, r, "test/terraform.tfstate")
+	}))
+	defer ts.Close()
+
+	// the workspace option takes precedence over the environment file
+	file := writeBackendState(t, "s3", map[string]interface{}{
+		"bucket":                      "mybucket",
+		"key":                         "path/to/terraform.tfstate",
+		"endpoint":                    ts.URL,
+		"force_path_style":            true,
+		"skip_credentials_validation": true,
+		"skip_region_validation":      true,
+	}, "prod")
+	state, err := tfstate.ReadFileWithOptions(file, tfstate.WithWorkspace("dev"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testLookupState(t, state)
+}
+
+func TestReadFileWithOptionsTimeout(t *testing.T) {
+	done := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+	defer ts.Close()
+	defer close(done)
+
+	file := writeBackendState(t, "http", map[string]interface{}{
+		"address": ts.URL + "/state",
+	}, "")
+	_, err := tfstate.ReadFileWithOptions(file,
+		tfstate.WithContext(context.Background()),
+		tfstate.WithTimeout(100*time.Millisecond),
+	)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestReadFileWithOptionsStrictWorkspace(t *testing.T) {
+	b, err := os.ReadFile("test/terraform.tfstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	file := filepath.Join(dir, "terraform.tfstate")
+	if err := os.WriteFile(file, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tfstate.ReadFileWithOptions(file); err != nil {
+		t.Errorf("a missing environment file must be ignored without the strict option: %s", err)
+	}
+	if _, err := tfstate.ReadFileWithOptions(file, tfstate.WithStrictWorkspace(true)); err == nil {
+		t.Error("expected an error for a missing environment file")
+	}
+	if _, err := tfstate.ReadFileWithOptions(file, tfstate.WithStrictWorkspace(true), tfstate.WithWorkspace("default")); err != nil {
+		t.Errorf("the environment file must not be read with the workspace option: %s", err)
+	}
+
+	env := filepath.Join(dir, "environment")
+	if err := os.WriteFile(env, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tfstate.ReadFileWithOptions(file, tfstate.WithStrictWorkspace(true)); err == nil {
+		t.Error("expected an error for an empty environment file")
+	}
+	if err := os.WriteFile(env, []byte("default"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	state, err := tfstate.ReadFileWithOptions(file, tfstate.WithStrictWorkspace(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testLookupState(t, state)
+}
+
+type testLogger struct {
+	messages []string
+}
+
+func (l *testLogger) Debug(msg string, args ...interface{}) {
+	l.messages = append(l.messages, fmt.Sprint(append([]interface{}{msg}, args...)...))
+}
+
+func TestReadFileWithOptionsLogger(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "test/terraform.tfstate")
+	}))
+	defer ts.Close()
+
+	file := writeBackendState(t, "http", map[string]interface{}{
+		"address": ts.URL + "/state",
+	}, "")
+	logger := &testLogger{}
+	if _, err := tfstate.ReadFileWithOptions(file, tfstate.WithLogger(logger)); err != nil {
+		t.Fatal(err)
+	}
+	if len(logger.messages) != 1 || !strings.Contains(logger.messages[0], "http backend (address="+ts.URL+"/state)") {
+		t.Errorf("unexpected messages %v", logger.messages)
+	}
+}
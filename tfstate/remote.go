@@ -1,9 +1,19 @@
 package tfstate
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
 )
 
 func strp(v interface{}) *string {
@@ -27,17 +37,162 @@ func strpe(v interface{}) *string {
 	return &empty
 }
 
+func boolv(v interface{}) bool {
+	switch vv := v.(type) {
+	case bool:
+		return vv
+	case string:
+		b, _ := strconv.ParseBool(vv)
+		return b
+	}
+	return false
+}
+
+// readMaybeGzipped returns a reader for b, decompressing it if it is gzipped
+func readMaybeGzipped(b []byte) (io.ReadCloser, error) {
+	if len(b) > 2 && b[0] == 0x1f && b[1] == 0x8b {
+		return gzip.NewReader(bytes.NewReader(b))
+	}
+	return io.NopCloser(bytes.NewReader(b)), nil
+}
+
+// BackendFunc reads a state of the workspace from the remote backend.
+// config is a backend config in the tfstate as is (values may be nested maps or lists).
+type BackendFunc func(ctx context.Context, config map[string]interface{}, ws string) (io.ReadCloser, error)
+
+var (
+	backendsMu sync.RWMutex
+	backends   = map[string]BackendFunc{}
+)
+
+// RegisterBackend registers a BackendFunc for the backend type name.
+// It overrides a built-in backend when the name is the same.
+func RegisterBackend(name string, fn BackendFunc) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+	backends[name] = fn
+}
+
+func init() {
+	for name, fn := range map[string]BackendFunc{
+		"gcs":         readGCSState,
+		"azurerm":     readAzureRMState,
+		"s3":          readS3State,
+		"remote":      readTFEState,
+		"cloud":       readTFEState,
+		"consul":      readConsulState,
+		"http":        readHTTPState,
+		"kubernetes":  readKubernetesState,
+		"pg":          readPGState,
+		"etcdv3":      readEtcdv3State,
+		"oss":         readOSSState,
+		"swift":       readSwiftState,
+		"cos":         readCOSState,
+		"artifactory": readArtifactoryState,
+	} {
+		RegisterBackend(name, fn)
+	}
+}
+
 func readRemoteState(ctx context.Context, b *backend, ws string) (io.ReadCloser, error) {
-	switch b.Type {
-	case "gcs":
-		return readGCSState(ctx, b.Config, ws)
-	case "azurerm":
-		return readAzureRMState(ctx, b.Config, ws)
-	case "s3":
-		return readS3State(ctx, b.Config, ws)
-	case "remote":
-		return readTFEState(ctx, b.Config, ws)
-	default:
+	backendsMu.RLock()
+	fn, ok := backends[b.Type]
+	backendsMu.RUnlock()
+	if !ok {
 		return nil, fmt.Errorf("backend type %s is not supported", b.Type)
 	}
+	loggerFrom(ctx).Debug("reading the remote state", "backend", b.Type, "workspace", ws, "location", describeBackend(b, ws))
+	r, err := fn(ctx, b.Config, ws)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, errors.Wrapf(err, "timed out reading the state from %s", describeBackend(b, ws))
+	}
+	return r, err
+}
+
+// Backend returns the type and the config of the backend which the state was read from.
+// Config values other than strings are encoded as JSON, such as true and {"s3":"http://localhost:9000"}.
+// ok is false when the state is not read from a backend.
+func (s *TFState) Backend() (typ string, config map[string]string, ok bool) {
+	if s.backend == nil {
+		return "", nil, false
+	}
+	config = make(map[string]string, len(s.backend.Config))
+	for key, v := range s.backend.Config {
+		if vs, isString := v.(string); isString {
+			config[key] = vs
+			continue
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			continue
+		}
+		config[key] = string(b)
+	}
+	return s.backend.Type, config, true
+}
+
+// workspaceListers list workspaces in the backend config, including the default workspace.
+var workspaceListers = map[string]func(ctx context.Context, config map[string]interface{}) ([]string, error){
+	"s3":  listS3Workspaces,
+	"gcs": listGCSWorkspaces,
+}
+
+// Workspaces lists workspaces in the backend which the state was read from.
+// The default workspace comes first, others are sorted. Only s3 and gcs backends are supported.
+func (s *TFState) Workspaces() ([]string, error) {
+	return s.WorkspacesContext(context.Background())
+}
+
+// WorkspacesContext lists workspaces as Workspaces does with the context.
+func (s *TFState) WorkspacesContext(ctx context.Context) ([]string, error) {
+	if s.backend == nil {
+		return nil, errors.New("the state is not read from a backend")
+	}
+	fn, ok := workspaceListers[s.backend.Type]
+	if !ok {
+		return nil, errors.Errorf("listing workspaces is not supported for %s backend", s.backend.Type)
+	}
+	workspaces, err := fn(ctx, s.backend.Config)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list workspaces in %s backend", s.backend.Type)
+	}
+	sort.Strings(workspaces[1:])
+	return workspaces, nil
+}
+
+// describeBackend returns a description of the state location in the backend for error messages.
+func describeBackend(b *backend, ws string) string {
+	var parts []string
+	for _, key := range []string{"bucket", "storage_account_name", "container_name", "organization", "address", "url", "repo", "container", "path", "prefix", "key", "subpath", "secret_suffix", "schema_name"} {
+		if isSensitiveKey(key) {
+			continue
+		}
+		if v := strp(b.Config[key]); v != nil && *v != "" {
+			parts = append(parts, key+"="+redactURL(*v))
+		}
+	}
+	if ws != defaultWorkspace {
+		parts = append(parts, "workspace="+ws)
+	}
+	return fmt.Sprintf("%s backend (%s)", b.Type, strings.Join(parts, ", "))
+}
+
+// isSensitiveKey reports whether the config key may hold a credential.
+func isSensitiveKey(key string) bool {
+	for _, s := range []string{"password", "token", "access_key", "secret_key", "client_secret", "sas"} {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactURL removes the user info from v when v is a URL, such as the address of the http backend.
+func redactURL(v string) string {
+	u, err := url.Parse(v)
+	if err != nil || u.User == nil {
+		return v
+	}
+	u.User = nil
+	return u.String()
 }
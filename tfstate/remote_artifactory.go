@@ -0,0 +1,40 @@
+package tfstate
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	artifactoryStateName            = "terraform.tfstate"
+	artifactoryWorkspacePlaceholder = "{workspace}"
+)
+
+func readArtifactoryState(ctx context.Context, config map[string]interface{}, ws string) (io.ReadCloser, error) {
+	baseURL, repo, subpath := *strpe(config["url"]), *strpe(config["repo"]), *strpe(config["subpath"])
+	if baseURL == "" {
+		baseURL = os.Getenv("ARTIFACTORY_URL")
+	}
+	if baseURL == "" || repo == "" || subpath == "" {
+		return nil, errors.New("url, repo and subpath are required for artifactory backend")
+	}
+	// artifactory backend doesn't support workspaces, so the workspace is used only when subpath has a placeholder
+	subpath = strings.ReplaceAll(subpath, artifactoryWorkspacePlaceholder, ws)
+
+	opt := httpOption{
+		username: *strpe(config["username"]),
+		password: *strpe(config["password"]),
+	}
+	if opt.username == "" {
+		opt.username = os.Getenv("ARTIFACTORY_USERNAME")
+	}
+	if opt.password == "" {
+		opt.password = os.Getenv("ARTIFACTORY_PASSWORD")
+	}
+	u := strings.TrimRight(baseURL, "/") + "/" + repo + "/" + strings.Trim(subpath, "/") + "/" + artifactoryStateName
+	return readHTTP(ctx, u, opt)
+}
@@ -4,25 +4,42 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net/url"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/storage/armstorage"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
 
 	"github.com/Azure/go-autorest/autorest/azure/cli"
 
 	"github.com/pkg/errors"
 )
 
+const (
+	azureRMAuthSASToken     = "SAS token"
+	azureRMAuthAccessKey    = "access key"
+	azureRMAuthMSI          = "managed identity"
+	azureRMAuthClientSecret = "client secret"
+	azureRMAuthProfile      = "access key from Azure profile"
+)
+
 type azureRMOption struct {
 	accessKey      string
+	sasToken       string
 	subscriptionID string
+	useMSI         bool
+	clientID       string
+	clientSecret   string
+	tenantID       string
 }
 
 func readAzureRMState(ctx context.Context, config map[string]interface{}, ws string) (io.ReadCloser, error) {
-	accountName, containerName, key := *strp(config["storage_account_name"]), *strpe(config["container_name"]), *strpe(config["key"])
-	resourceGroupName := *strp(config["resource_group_name"])
+	accountName, containerName, key := *strpe(config["storage_account_name"]), *strpe(config["container_name"]), *strpe(config["key"])
+	resourceGroupName := *strpe(config["resource_group_name"])
 	if ws != defaultWorkspace {
 		if prefix := strp(config["workspace_key_prefix"]); prefix != nil {
 			key = key + *prefix + ws
@@ -30,52 +47,153 @@ func readAzureRMState(ctx context.Context, config map[string]interface{}, ws str
 			key = key + defaultWorkspeceKeyPrefix + ws
 		}
 	}
+	opt, err := azureRMOptionFromConfig(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	return readAzureRM(ctx, resourceGroupName, accountName, containerName, key, opt)
+}
+
+func azureRMOptionFromConfig(ctx context.Context, config map[string]interface{}) (azureRMOption, error) {
 	opt := azureRMOption{
 		accessKey:      *strpe(config["access_key"]),
+		sasToken:       *strpe(config["sas_token"]),
 		subscriptionID: *strpe(config["subscription_id"]),
+		useMSI:         boolv(config["use_msi"]) || boolv(os.Getenv("ARM_USE_MSI")),
+		clientID:       *strpe(config["client_id"]),
+		clientSecret:   *strpe(config["client_secret"]),
+		tenantID:       *strpe(config["tenant_id"]),
 	}
-	return readAzureRM(ctx, resourceGroupName, accountName, containerName, key, opt)
+	for _, v := range []struct {
+		value *string
+		env   string
+	}{
+		{&opt.sasToken, "ARM_SAS_TOKEN"},
+		{&opt.clientID, "ARM_CLIENT_ID"},
+		{&opt.clientSecret, "ARM_CLIENT_SECRET"},
+		{&opt.tenantID, "ARM_TENANT_ID"},
+	} {
+		if *v.value == "" {
+			*v.value = os.Getenv(v.env)
+		}
+	}
+	creds, err := credentialsFor(ctx, "azurerm", config)
+	if err != nil {
+		return opt, err
+	}
+	if creds.AzureAccessKey != "" {
+		opt.accessKey = creds.AzureAccessKey
+	}
+	if creds.AzureSASToken != "" {
+		opt.sasToken = creds.AzureSASToken
+	}
+	return opt, nil
 }
 
 func readAzureRM(ctx context.Context, resourceGroupName string, accountName string, containerName string, key string, opt azureRMOption) (io.ReadCloser, error) {
+	client, method, err := newAzureRMClient(ctx, resourceGroupName, accountName, opt)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to setup client for storage account %s (auth: %s)", accountName, method)
+	}
+	loggerFrom(ctx).Debug("azurerm blob", "storage_account_name", accountName, "container_name", containerName, "key", key, "auth", method)
+
+	blobDownloadResponse, err := client.DownloadStream(ctx, containerName, key, nil)
+	if err != nil {
+		if method == azureRMAuthSASToken && bloberror.HasCode(err, bloberror.AuthenticationFailed, bloberror.AuthorizationFailure) {
+			return nil, errors.Wrapf(err, "failed to download blob %s from %s/%s: SAS token is expired or not permitted", key, accountName, containerName)
+		}
+		return nil, errors.Wrapf(err, "failed to download blob %s from %s/%s (auth: %s)", key, accountName, containerName, method)
+	}
+
+	r := blobDownloadResponse.Body
+	return r, nil
+}
+
+// newAzureRMClient returns a blob client and a name of the chosen authentication method.
+func newAzureRMClient(ctx context.Context, resourceGroupName string, accountName string, opt azureRMOption) (*azblob.Client, string, error) {
 	serviceUrl := fmt.Sprintf("https://%s.blob.core.windows.net/", accountName)
-	//get blob access key
-	var accountKey string
-	for _, gen := range []func() (string, error){
-		func() (string, error) { return opt.accessKey, nil },
-		func() (string, error) { return os.Getenv("AZURE_STORAGE_ACCESS_KEY"), nil },
-		func() (string, error) { return getDefaultAccessKey(ctx, resourceGroupName, accountName, opt) },
-	} {
-		key, err := gen()
+
+	// SAS token in terraform config or ENV
+	if opt.sasToken != "" {
+		sasToken := strings.TrimPrefix(opt.sasToken, "?")
+		if err := checkAzureRMSASTokenExpiry(sasToken); err != nil {
+			return nil, azureRMAuthSASToken, err
+		}
+		client, err := azblob.NewClientWithNoCredential(serviceUrl+"?"+sasToken, nil)
+		return client, azureRMAuthSASToken, err
+	}
+
+	// access key in terraform config or ENV
+	for _, accountKey := range []string{opt.accessKey, os.Getenv("ARM_ACCESS_KEY"), os.Getenv("AZURE_STORAGE_ACCESS_KEY")} {
+		if accountKey != "" {
+			client, err := newAzureRMSharedKeyClient(serviceUrl, accountName, accountKey)
+			return client, azureRMAuthAccessKey, err
+		}
+	}
+
+	// Azure AD authentication
+	if opt.useMSI {
+		var msiOpt *azidentity.ManagedIdentityCredentialOptions
+		if opt.clientID != "" {
+			msiOpt = &azidentity.ManagedIdentityCredentialOptions{ID: azidentity.ClientID(opt.clientID)}
+		}
+		cred, err := azidentity.NewManagedIdentityCredential(msiOpt)
 		if err != nil {
-			return nil, err
-		} else if key != "" {
-			accountKey = key
-			break
+			return nil, azureRMAuthMSI, err
 		}
+		client, err := azblob.NewClient(serviceUrl, cred, nil)
+		return client, azureRMAuthMSI, err
 	}
-	if accountKey == "" {
-		return nil, errors.New("Blob access key not found in ENV, terraform config and can't be fetched from current Azure Profile")
+	if opt.clientID != "" && opt.clientSecret != "" && opt.tenantID != "" {
+		cred, err := azidentity.NewClientSecretCredential(opt.tenantID, opt.clientID, opt.clientSecret, nil)
+		if err != nil {
+			return nil, azureRMAuthClientSecret, err
+		}
+		client, err := azblob.NewClient(serviceUrl, cred, nil)
+		return client, azureRMAuthClientSecret, err
 	}
 
-	// Authenticate
-	credential, err := azblob.NewSharedKeyCredential(accountName, accountKey)
+	// access key fetched from current Azure Profile
+	accountKey, err := getDefaultAccessKey(ctx, resourceGroupName, accountName, opt)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to create credential")
+		return nil, azureRMAuthProfile, errors.Wrapf(err, "failed to get access key for storage account %s", accountName)
 	}
+	if accountKey == "" {
+		return nil, azureRMAuthProfile, errors.Errorf("Blob access key for storage account %s not found in ENV, terraform config and can't be fetched from current Azure Profile", accountName)
+	}
+	client, err := newAzureRMSharedKeyClient(serviceUrl, accountName, accountKey)
+	return client, azureRMAuthProfile, err
+}
 
-	client, err := azblob.NewClientWithSharedKeyCredential(serviceUrl, credential, nil)
+// checkAzureRMSASTokenExpiry returns an error if the SAS token has been expired (se parameter).
+func checkAzureRMSASTokenExpiry(sasToken string) error {
+	values, err := url.ParseQuery(sasToken)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to setup client")
+		return errors.Wrap(err, "invalid SAS token")
 	}
-
-	blobDownloadResponse, err := client.DownloadStream(ctx, containerName, key, nil)
+	se := values.Get("se")
+	if se == "" {
+		return nil
+	}
+	expiry, err := time.Parse(time.RFC3339, se)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to download blob")
+		// se may be a date only
+		if expiry, err = time.Parse("2006-01-02", se); err != nil {
+			return errors.Wrapf(err, "invalid expiry of SAS token %s", se)
+		}
+	}
+	if time.Now().After(expiry) {
+		return errors.Errorf("SAS token has been expired at %s", expiry.Format(time.RFC3339))
 	}
+	return nil
+}
 
-	r := blobDownloadResponse.Body
-	return r, nil
+func newAzureRMSharedKeyClient(serviceUrl, accountName, accountKey string) (*azblob.Client, error) {
+	credential, err := azblob.NewSharedKeyCredential(accountName, accountKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create credential")
+	}
+	return azblob.NewClientWithSharedKeyCredential(serviceUrl, credential, nil)
 }
 
 func getDefaultSubscription() (string, error) {
@@ -114,10 +232,16 @@ func getDefaultAccessKey(ctx context.Context, resourceGroupName string, accountN
 	}
 
 	clientFactory, err := armstorage.NewClientFactory(subscriptionID, cred, nil)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to setup storage client")
+	}
 	keys, err := clientFactory.NewAccountsClient().ListKeys(ctx, resourceGroupName, accountName, nil)
 	if err != nil {
 		return "", errors.Wrap(err, "failed to list keys")
 	}
+	if len(keys.Keys) == 0 || keys.Keys[0].Value == nil {
+		return "", errors.Errorf("no access keys found for storage account %s", accountName)
+	}
 
 	return *keys.Keys[0].Value, nil
 }
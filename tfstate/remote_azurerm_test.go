@@ -0,0 +1,66 @@
+package tfstate_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/fujiwara/tfstate-lookup/tfstate"
+)
+
+func TestReadAzureRMExpiredSASToken(t *testing.T) {
+	t.Setenv("ARM_ACCESS_KEY", "")
+	t.Setenv("AZURE_STORAGE_ACCESS_KEY", "")
+	file := writeBackendState(t, "azurerm", map[string]interface{}{
+		"storage_account_name": "tfstate",
+		"container_name":       "tfstate",
+		"key":                  "terraform.tfstate",
+		"sas_token":            "?sv=2021-06-08&ss=b&srt=sco&sp=rl&se=2020-01-01T00:00:00Z&st=2019-01-01T00:00:00Z&spr=https&sig=dummy",
+	}, "")
+	_, err := tfstate.ReadFile(context.Background(), file)
+	if err == nil || !strings.Contains(err.Error(), "expired at 2020-01-01T00:00:00Z") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestAzureRMCredentialProvider(t *testing.T) {
+	t.Setenv("ARM_SAS_TOKEN", "env-sas-token")
+	config := map[string]interface{}{
+		"storage_account_name": "tfstate",
+		"container_name":       "tfstate",
+		"key":                  "terraform.tfstate",
+		"access_key":           "config-access-key",
+		"sas_token":            "config-sas-token",
+	}
+	provider := tfstate.CredentialProviderFunc(func(ctx context.Context, typ string, config map[string]interface{}) (*tfstate.Credentials, error) {
+		if typ != "azurerm" {
+			t.Errorf("unexpected backend type %s", typ)
+		}
+		return &tfstate.Credentials{AzureAccessKey: "provided-access-key", AzureSASToken: "provided-sas-token"}, nil
+	})
+	accessKey, sasToken, err := tfstate.AzureRMKeys(provider, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if accessKey != "provided-access-key" || sasToken != "provided-sas-token" {
+		t.Errorf("unexpected access key %s and sas token %s", accessKey, sasToken)
+	}
+
+	// the sas token in the environment is overridden by the provider too
+	delete(config, "sas_token")
+	_, sasToken, err = tfstate.AzureRMKeys(provider, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sasToken != "provided-sas-token" {
+		t.Errorf("unexpected sas token %s", sasToken)
+	}
+
+	accessKey, sasToken, err = tfstate.AzureRMKeys(nil, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if accessKey != "config-access-key" || sasToken != "env-sas-token" {
+		t.Errorf("unexpected access key %s and sas token %s", accessKey, sasToken)
+	}
+}
@@ -0,0 +1,94 @@
+package tfstate
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+
+	consul "github.com/hashicorp/consul/api"
+	"github.com/pkg/errors"
+)
+
+const consulKeyEnvPrefix = "-env:"
+
+type consulOption struct {
+	address    string
+	scheme     string
+	datacenter string
+	token      string
+}
+
+// consulChunkedState is a payload which Terraform stores when the state is too large for a single KV entry.
+type consulChunkedState struct {
+	CurrentHash string   `json:"current-hash"`
+	Chunks      []string `json:"chunks"`
+}
+
+func readConsulState(ctx context.Context, config map[string]interface{}, ws string) (io.ReadCloser, error) {
+	path := *strpe(config["path"])
+	if path == "" {
+		return nil, errors.New("path is required for consul backend")
+	}
+	if ws != defaultWorkspace {
+		path = path + consulKeyEnvPrefix + ws
+	}
+	opt := consulOption{
+		address:    *strpe(config["address"]),
+		scheme:     *strpe(config["scheme"]),
+		datacenter: *strpe(config["datacenter"]),
+		token:      *strpe(config["access_token"]),
+	}
+	return readConsul(ctx, path, opt)
+}
+
+func readConsul(ctx context.Context, path string, opt consulOption) (io.ReadCloser, error) {
+	// DefaultConfig reads CONSUL_HTTP_ADDR, CONSUL_HTTP_TOKEN and so on
+	cfg := consul.DefaultConfig()
+	if opt.address != "" {
+		cfg.Address = opt.address
+	}
+	if opt.scheme != "" {
+		cfg.Scheme = opt.scheme
+	}
+	if opt.datacenter != "" {
+		cfg.Datacenter = opt.datacenter
+	}
+	if opt.token != "" {
+		cfg.Token = opt.token
+	}
+	client, err := consul.NewClient(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to setup consul client")
+	}
+	kv := client.KV()
+	b, err := getConsulKV(ctx, kv, path)
+	if err != nil {
+		return nil, err
+	}
+
+	var chunked consulChunkedState
+	if err := json.Unmarshal(b, &chunked); err == nil && len(chunked.Chunks) > 0 {
+		var buf bytes.Buffer
+		for _, chunk := range chunked.Chunks {
+			cb, err := getConsulKV(ctx, kv, chunk)
+			if err != nil {
+				return nil, err
+			}
+			buf.Write(cb)
+		}
+		b = buf.Bytes()
+	}
+	return readMaybeGzipped(b)
+}
+
+func getConsulKV(ctx context.Context, kv *consul.KV, path string) ([]byte, error) {
+	pair, _, err := kv.Get(path, (&consul.QueryOptions{}).WithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get %s from consul", path)
+	}
+	if pair == nil {
+		return nil, errors.Errorf("state %s is not found in consul", path)
+	}
+	return pair.Value, nil
+}
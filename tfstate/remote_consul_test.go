@@ -0,0 +1,73 @@
+package tfstate_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/fujiwara/tfstate-lookup/tfstate"
+)
+
+func newConsulServer(t *testing.T, kv map[string][]byte) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := strings.TrimPrefix(r.URL.Path, "/v1/kv/")
+		value, ok := kv[key]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode([]map[string]interface{}{
+			{"Key": key, "Value": value},
+		})
+	}))
+}
+
+func TestReadConsul(t *testing.T) {
+	b, err := os.ReadFile("test/terraform.tfstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write(b)
+	w.Close()
+
+	ts := newConsulServer(t, map[string][]byte{
+		"terraform/state":         b,
+		"terraform/state-env:dev": gz.Bytes(),
+	})
+	defer ts.Close()
+
+	for _, ws := range []string{"", "dev"} {
+		file := writeBackendState(t, "consul", map[string]interface{}{
+			"address": strings.TrimPrefix(ts.URL, "http://"),
+			"scheme":  "http",
+			"path":    "terraform/state",
+		}, ws)
+		state, err := tfstate.ReadFile(context.Background(), file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		testLookupState(t, state)
+	}
+
+	file := writeBackendState(t, "consul", map[string]interface{}{
+		"address": strings.TrimPrefix(ts.URL, "http://"),
+		"path":    "terraform/state",
+	}, "prod")
+	if _, err := tfstate.ReadFile(context.Background(), file); err == nil {
+		t.Error("must be failed for a missing workspace")
+	}
+	// the workspace argument takes precedence over the environment file
+	state, err := tfstate.ReadFileWithWorkspace(context.Background(), file, "dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testLookupState(t, state)
+}
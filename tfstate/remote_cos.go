@@ -0,0 +1,98 @@
+package tfstate
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+
+	"github.com/pkg/errors"
+	cos "github.com/tencentyun/cos-go-sdk-v5"
+)
+
+const (
+	defaultCOSPrefix = "env:"
+	defaultCOSKey    = "terraform.tfstate"
+)
+
+type cosOption struct {
+	region        string
+	endpoint      string
+	secretID      string
+	secretKey     string
+	securityToken string
+}
+
+func readCOSState(ctx context.Context, config map[string]interface{}, ws string) (io.ReadCloser, error) {
+	bucket := *strpe(config["bucket"])
+	if bucket == "" {
+		return nil, errors.New("bucket is required for cos backend")
+	}
+	prefix, key := defaultCOSPrefix, defaultCOSKey
+	if p := strp(config["prefix"]); p != nil {
+		prefix = *p
+	}
+	if k := strp(config["key"]); k != nil && *k != "" {
+		key = *k
+	}
+	if ws == defaultWorkspace {
+		key = path.Join(prefix, key)
+	} else {
+		key = path.Join(prefix, ws, key)
+	}
+
+	opt := cosOption{
+		region:        *strpe(config["region"]),
+		endpoint:      *strpe(config["endpoint"]),
+		secretID:      *strpe(config["secret_id"]),
+		secretKey:     *strpe(config["secret_key"]),
+		securityToken: *strpe(config["security_token"]),
+	}
+	for _, v := range []struct {
+		value *string
+		env   string
+	}{
+		{&opt.region, "TENCENTCLOUD_REGION"},
+		{&opt.secretID, "TENCENTCLOUD_SECRET_ID"},
+		{&opt.secretKey, "TENCENTCLOUD_SECRET_KEY"},
+		{&opt.securityToken, "TENCENTCLOUD_SECURITY_TOKEN"},
+	} {
+		if *v.value == "" {
+			*v.value = os.Getenv(v.env)
+		}
+	}
+	return readCOS(ctx, bucket, key, opt)
+}
+
+func readCOS(ctx context.Context, bucket, key string, opt cosOption) (io.ReadCloser, error) {
+	var bucketURL *url.URL
+	var err error
+	if opt.endpoint != "" {
+		bucketURL, err = url.Parse(opt.endpoint)
+	} else {
+		if opt.region == "" {
+			return nil, errors.New("region is required for cos backend")
+		}
+		bucketURL, err = cos.NewBucketURL(bucket, opt.region, true)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid cos bucket url")
+	}
+	client := cos.NewClient(&cos.BaseURL{BucketURL: bucketURL}, &http.Client{
+		Transport: &cos.AuthorizationTransport{
+			SecretID:     opt.secretID,
+			SecretKey:    opt.secretKey,
+			SessionToken: opt.securityToken,
+		},
+	})
+	resp, err := client.Object.Get(ctx, key, nil)
+	if err != nil {
+		if cos.IsNotFoundError(err) {
+			return nil, errors.Errorf("state object cos://%s/%s is not found", bucket, key)
+		}
+		return nil, errors.Wrapf(err, "failed to get cos://%s/%s", bucket, key)
+	}
+	return resp.Body, nil
+}
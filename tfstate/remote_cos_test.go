@@ -0,0 +1,51 @@
+package tfstate_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fujiwara/tfstate-lookup/tfstate"
+)
+
+func TestReadCOS(t *testing.T) {
+	t.Setenv("TENCENTCLOUD_SECRET_ID", "id")
+	t.Setenv("TENCENTCLOUD_SECRET_KEY", "key")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.Header.Get("Authorization"), "q-ak=id") {
+			t.Errorf("unexpected authorization header %s", r.Header.Get("Authorization"))
+		}
+		switch r.URL.Path {
+		case "/env:/terraform.tfstate", "/env:/dev/terraform.tfstate":
+			http.ServeFile(w, r, "test/terraform.tfstate")
+		default:
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?><Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>`))
+		}
+	}))
+	defer ts.Close()
+
+	config := map[string]interface{}{
+		"bucket":   "tfstate-1250000000",
+		"region":   "ap-guangzhou",
+		"endpoint": ts.URL,
+	}
+	for _, ws := range []string{"", "dev"} {
+		file := writeBackendState(t, "cos", config, ws)
+		state, err := tfstate.ReadFile(context.Background(), file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		testLookupState(t, state)
+	}
+
+	file := writeBackendState(t, "cos", config, "prod")
+	_, err := tfstate.ReadFile(context.Background(), file)
+	if err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
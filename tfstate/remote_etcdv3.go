@@ -0,0 +1,84 @@
+package tfstate
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.etcd.io/etcd/client/pkg/v3/transport"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+const etcdv3DialTimeout = 5 * time.Second
+
+type etcdv3Option struct {
+	endpoints  []string
+	username   string
+	password   string
+	cacertPath string
+	certPath   string
+	keyPath    string
+}
+
+func readEtcdv3State(ctx context.Context, config map[string]interface{}, ws string) (io.ReadCloser, error) {
+	opt := etcdv3Option{
+		username:   *strpe(config["username"]),
+		password:   *strpe(config["password"]),
+		cacertPath: *strpe(config["cacert_path"]),
+		certPath:   *strpe(config["cert_path"]),
+		keyPath:    *strpe(config["key_path"]),
+	}
+	if endpoints, ok := config["endpoints"].([]interface{}); ok {
+		for _, endpoint := range endpoints {
+			if e := strp(endpoint); e != nil {
+				opt.endpoints = append(opt.endpoints, *e)
+			}
+		}
+	}
+	if len(opt.endpoints) == 0 {
+		return nil, errors.New("endpoints is required for etcdv3 backend")
+	}
+	key := *strpe(config["prefix"]) + ws
+	return readEtcdv3(ctx, key, opt)
+}
+
+func readEtcdv3(ctx context.Context, key string, opt etcdv3Option) (io.ReadCloser, error) {
+	cfg := clientv3.Config{
+		Endpoints:   opt.endpoints,
+		Username:    opt.username,
+		Password:    opt.password,
+		DialTimeout: etcdv3DialTimeout,
+		DialOptions: []grpc.DialOption{grpc.WithBlock()},
+		Context:     ctx,
+		Logger:      zap.NewNop(),
+	}
+	if opt.cacertPath != "" || opt.certPath != "" {
+		tlsInfo := transport.TLSInfo{
+			TrustedCAFile: opt.cacertPath,
+			CertFile:      opt.certPath,
+			KeyFile:       opt.keyPath,
+		}
+		tlsConfig, err := tlsInfo.ClientConfig()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load TLS config for etcd")
+		}
+		cfg.TLS = tlsConfig
+	}
+	client, err := clientv3.New(cfg)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to connect to etcd %v", opt.endpoints)
+	}
+	defer client.Close()
+
+	res, err := client.Get(ctx, key)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get %s from etcd", key)
+	}
+	if len(res.Kvs) == 0 {
+		return nil, errors.Errorf("state %s is not found in etcd", key)
+	}
+	return readMaybeGzipped(res.Kvs[0].Value)
+}
@@ -3,34 +3,201 @@ package tfstate
 import (
 	"context"
 	"encoding/base64"
+	"encoding/json"
 	"io"
+	"os"
 	"path"
+	"strings"
+	"time"
 
 	"cloud.google.com/go/storage"
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+	iamcredentials "google.golang.org/api/iamcredentials/v1"
+	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 )
 
+type gcsOption struct {
+	credentials                        string
+	encryption_key                     string
+	impersonateServiceAccount          string
+	impersonateServiceAccountDelegates []string
+}
+
 func readGCSState(ctx context.Context, config map[string]interface{}, ws string) (io.ReadCloser, error) {
-	bucket := *strp(config["bucket"])
+	bucket := *strpe(config["bucket"])
+	if bucket == "" {
+		return nil, errors.New("bucket is required for gcs backend")
+	}
 	prefix := *strpe(config["prefix"])
-	credentials := *strpe(config["credentials"])
-	encryption_key := *strpe(config["encryption_key"])
+	opt, err := gcsOptionFromConfig(ctx, config)
+	if err != nil {
+		return nil, err
+	}
 
 	key := path.Join(prefix, ws+".tfstate")
+	loggerFrom(ctx).Debug("gcs object", "bucket", bucket, "key", key, "impersonate_service_account", opt.impersonateServiceAccount)
 
-	return readGCS(ctx, bucket, key, credentials, encryption_key)
+	return readGCS(ctx, bucket, key, opt)
 }
 
-func readGCS(ctx context.Context, bucket, key, credentials, encryption_key string) (io.ReadCloser, error) {
-	var err error
+func gcsOptionFromConfig(ctx context.Context, config map[string]interface{}) (gcsOption, error) {
+	opt := gcsOption{
+		credentials:               *strpe(config["credentials"]),
+		encryption_key:            *strpe(config["encryption_key"]),
+		impersonateServiceAccount: *strpe(config["impersonate_service_account"]),
+	}
+	if delegates, ok := config["impersonate_service_account_delegates"].([]interface{}); ok {
+		for _, d := range delegates {
+			if v := strp(d); v != nil {
+				opt.impersonateServiceAccountDelegates = append(opt.impersonateServiceAccountDelegates, *v)
+			}
+		}
+	}
 
-	var client *storage.Client
-	if credentials != "" {
-		client, err = storage.NewClient(ctx, option.WithCredentialsFile(credentials))
-	} else {
-		client, err = storage.NewClient(ctx)
+	creds, err := credentialsFor(ctx, "gcs", config)
+	if err != nil {
+		return opt, err
+	}
+	if creds.GoogleCredentials != "" {
+		opt.credentials = creds.GoogleCredentials
+	}
+	return opt, nil
+}
+
+// listGCSWorkspaces lists workspaces which have the state object at <prefix>/<workspace>.tfstate.
+func listGCSWorkspaces(ctx context.Context, config map[string]interface{}) ([]string, error) {
+	bucket := *strpe(config["bucket"])
+	if bucket == "" {
+		return nil, errors.New("bucket is required for gcs backend")
+	}
+	prefix := *strpe(config["prefix"])
+	if prefix != "" {
+		prefix = strings.TrimSuffix(prefix, "/") + "/"
+	}
+	opt, err := gcsOptionFromConfig(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	opts, err := gcsClientOptions(ctx, opt)
+	if err != nil {
+		return nil, err
+	}
+	client, err := storage.NewClient(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	workspaces := []string{defaultWorkspace}
+	it := client.Bucket(bucket).Objects(ctx, &storage.Query{Prefix: prefix, Delimiter: "/"})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list objects in gs://%s/%s", bucket, prefix)
+		}
+		name := strings.TrimPrefix(attrs.Name, prefix)
+		if ws := strings.TrimSuffix(name, ".tfstate"); ws != name && ws != "" && ws != defaultWorkspace {
+			workspaces = append(workspaces, ws)
+		}
+	}
+	return workspaces, nil
+}
+
+// impersonatedTokenSource generates access tokens of the service account by IAM Credentials API
+type impersonatedTokenSource struct {
+	ctx       context.Context
+	service   *iamcredentials.Service
+	target    string
+	delegates []string
+}
+
+func (s *impersonatedTokenSource) Token() (*oauth2.Token, error) {
+	delegates := make([]string, 0, len(s.delegates))
+	for _, d := range s.delegates {
+		delegates = append(delegates, "projects/-/serviceAccounts/"+d)
+	}
+	res, err := s.service.Projects.ServiceAccounts.GenerateAccessToken(
+		"projects/-/serviceAccounts/"+s.target,
+		&iamcredentials.GenerateAccessTokenRequest{
+			Scope:     []string{storage.ScopeReadOnly},
+			Delegates: delegates,
+		},
+	).Context(s.ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+	expiry, err := time.Parse(time.RFC3339, res.ExpireTime)
+	if err != nil {
+		return nil, err
+	}
+	return &oauth2.Token{AccessToken: res.AccessToken, Expiry: expiry}, nil
+}
+
+// gcsCredentialsOption returns a client option for credentials which is a file path or JSON content.
+// The credentials are read from terraform config, GOOGLE_BACKEND_CREDENTIALS or GOOGLE_CREDENTIALS in order.
+// If nothing found, Application Default Credentials (including GOOGLE_APPLICATION_CREDENTIALS) are used.
+func gcsCredentialsOption(credentials string) (option.ClientOption, error) {
+	source := "credentials"
+	for _, env := range []string{"GOOGLE_BACKEND_CREDENTIALS", "GOOGLE_CREDENTIALS"} {
+		if credentials != "" {
+			break
+		}
+		credentials, source = os.Getenv(env), env
+	}
+	if credentials == "" {
+		return nil, nil
+	}
+	if strings.HasPrefix(strings.TrimSpace(credentials), "{") {
+		if !json.Valid([]byte(credentials)) {
+			return nil, errors.Errorf("invalid %s: malformed JSON content", source)
+		}
+		return option.WithCredentialsJSON([]byte(credentials)), nil
+	}
+	return option.WithCredentialsFile(credentials), nil
+}
+
+func gcsClientOptions(ctx context.Context, opt gcsOption) ([]option.ClientOption, error) {
+	var opts []option.ClientOption
+	credsOpt, err := gcsCredentialsOption(opt.credentials)
+	if err != nil {
+		return nil, err
+	}
+	if credsOpt != nil {
+		opts = append(opts, credsOpt)
+	}
+	if opt.impersonateServiceAccount == "" {
+		return opts, nil
 	}
 
+	service, err := iamcredentials.NewService(ctx, opts...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to impersonate service account %s", opt.impersonateServiceAccount)
+	}
+	src := &impersonatedTokenSource{
+		ctx:       ctx,
+		service:   service,
+		target:    opt.impersonateServiceAccount,
+		delegates: opt.impersonateServiceAccountDelegates,
+	}
+	// generate a token at first to distinguish impersonation failures from others
+	token, err := src.Token()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to impersonate service account %s", opt.impersonateServiceAccount)
+	}
+	return []option.ClientOption{option.WithTokenSource(oauth2.ReuseTokenSource(token, src))}, nil
+}
+
+func readGCS(ctx context.Context, bucket, key string, opt gcsOption) (io.ReadCloser, error) {
+	opts, err := gcsClientOptions(ctx, opt)
+	if err != nil {
+		return nil, err
+	}
+	client, err := storage.NewClient(ctx, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -40,14 +207,17 @@ func readGCS(ctx context.Context, bucket, key, credentials, encryption_key strin
 
 	var r *storage.Reader
 
-	if encryption_key != "" {
-		decodedKey, _ := base64.StdEncoding.DecodeString(encryption_key)
+	if opt.encryption_key != "" {
+		decodedKey, _ := base64.StdEncoding.DecodeString(opt.encryption_key)
 		r, err = obj.Key(decodedKey).NewReader(ctx)
 	} else {
 		r, err = obj.NewReader(ctx)
 	}
 
 	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return nil, errors.Errorf("state object gs://%s/%s is not found", bucket, key)
+		}
 		return nil, err
 	}
 
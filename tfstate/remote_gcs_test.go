@@ -0,0 +1,54 @@
+package tfstate_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/fujiwara/tfstate-lookup/tfstate"
+)
+
+func TestReadGCSInvalidCredentials(t *testing.T) {
+	t.Setenv("GOOGLE_BACKEND_CREDENTIALS", "")
+	t.Setenv("GOOGLE_CREDENTIALS", `{"type": "service_account",`)
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "/path/to/credentials.json")
+
+	file := writeBackendState(t, "gcs", map[string]interface{}{
+		"bucket": "mybucket",
+		"prefix": "terraform/state",
+	}, "")
+	_, err := tfstate.ReadFile(context.Background(), file)
+	if err == nil || !strings.Contains(err.Error(), "invalid GOOGLE_CREDENTIALS") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestGCSCredentialProvider(t *testing.T) {
+	t.Setenv("GOOGLE_BACKEND_CREDENTIALS", "/path/to/backend.json")
+	t.Setenv("GOOGLE_CREDENTIALS", "/path/to/env.json")
+	config := map[string]interface{}{
+		"bucket":      "mybucket",
+		"credentials": "/path/to/config.json",
+	}
+	provider := tfstate.CredentialProviderFunc(func(ctx context.Context, typ string, config map[string]interface{}) (*tfstate.Credentials, error) {
+		if typ != "gcs" {
+			t.Errorf("unexpected backend type %s", typ)
+		}
+		return &tfstate.Credentials{GoogleCredentials: "/path/to/provided.json"}, nil
+	})
+	creds, err := tfstate.GCSCredentials(provider, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creds != "/path/to/provided.json" {
+		t.Errorf("unexpected credentials %s", creds)
+	}
+
+	creds, err = tfstate.GCSCredentials(nil, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creds != "/path/to/config.json" {
+		t.Errorf("unexpected credentials %s", creds)
+	}
+}
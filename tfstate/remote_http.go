@@ -2,18 +2,115 @@ package tfstate
 
 import (
 	"context"
+	"crypto/tls"
 	"io"
 	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
 )
 
-func readHTTP(ctx context.Context, u string) (io.ReadCloser, error) {
+// maxErrorBodySize is a maximum size of a response body which is included in an error message
+const maxErrorBodySize = 1024
+
+// defaultHTTPTimeout is a timeout of the http backend without timeout or TF_HTTP_TIMEOUT
+const defaultHTTPTimeout = 30 * time.Second
+
+type httpOption struct {
+	username             string
+	password             string
+	bearerToken          string
+	skipCertVerification bool
+	timeout              time.Duration
+}
+
+// parseTimeout parses a duration string (e.g. "30s") or a number of seconds
+func parseTimeout(v interface{}) (time.Duration, error) {
+	switch vv := v.(type) {
+	case nil:
+		return 0, nil
+	case float64:
+		return time.Duration(vv * float64(time.Second)), nil
+	case string:
+		if vv == "" {
+			return 0, nil
+		}
+		if sec, err := strconv.ParseFloat(vv, 64); err == nil {
+			return time.Duration(sec * float64(time.Second)), nil
+		}
+		return time.ParseDuration(vv)
+	}
+	return 0, errors.Errorf("invalid timeout %v", v)
+}
+
+func readHTTPState(ctx context.Context, config map[string]interface{}, ws string) (io.ReadCloser, error) {
+	// lock_address and unlock_address are ignored because tfstate-lookup never writes the state
+	address := *strpe(config["address"])
+	if address == "" {
+		address = os.Getenv("TF_HTTP_ADDRESS")
+	}
+	if address == "" {
+		return nil, errors.New("address is required for http backend")
+	}
+	opt := httpOption{
+		username:             *strpe(config["username"]),
+		password:             *strpe(config["password"]),
+		bearerToken:          os.Getenv("TF_HTTP_BEARER_TOKEN"),
+		skipCertVerification: boolv(config["skip_cert_verification"]),
+	}
+	for _, v := range []struct {
+		value *string
+		env   string
+	}{
+		{&opt.username, "TF_HTTP_USERNAME"},
+		{&opt.password, "TF_HTTP_PASSWORD"},
+	} {
+		if *v.value == "" {
+			*v.value = os.Getenv(v.env)
+		}
+	}
+	timeout := config["timeout"]
+	if timeout == nil {
+		timeout = os.Getenv("TF_HTTP_TIMEOUT")
+	}
+	var err error
+	if opt.timeout, err = parseTimeout(timeout); err != nil {
+		return nil, err
+	}
+	if opt.timeout == 0 {
+		opt.timeout = defaultHTTPTimeout
+	}
+	return readHTTP(ctx, address, opt)
+}
+
+func readHTTP(ctx context.Context, u string, opt httpOption) (io.ReadCloser, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
 	if err != nil {
 		return nil, err
 	}
-	resp, err := http.DefaultClient.Do(req)
+	if opt.username != "" {
+		req.SetBasicAuth(opt.username, opt.password)
+	} else if opt.bearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+opt.bearerToken)
+	}
+
+	// zero timeout means no timeout of the client, the ctx governs the request
+	client := &http.Client{Timeout: opt.timeout}
+	if opt.skipCertVerification {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+		client.Transport = transport
+	}
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, err
 	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+		return nil, errors.Errorf("unexpected response status %s: %s", resp.Status, string(body))
+	}
 	return resp.Body, nil
 }
@@ -0,0 +1,147 @@
+package tfstate_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fujiwara/tfstate-lookup/tfstate"
+)
+
+func TestReadHTTPBackend(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "gitlab-ci-token" || pass != "secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		http.ServeFile(w, r, "test/terraform.tfstate")
+	}))
+	defer ts.Close()
+
+	file := writeBackendState(t, "http", map[string]interface{}{
+		"address":        ts.URL + "/state",
+		"lock_address":   ts.URL + "/state/lock",
+		"unlock_address": ts.URL + "/state/lock",
+		"username":       "gitlab-ci-token",
+		"password":       "secret",
+	}, "")
+	state, err := tfstate.ReadFile(context.Background(), file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testLookupState(t, state)
+
+	file = writeBackendState(t, "http", map[string]interface{}{
+		"address":  ts.URL + "/state",
+		"username": "gitlab-ci-token",
+		"password": "invalid",
+	}, "")
+	_, err = tfstate.ReadFile(context.Background(), file)
+	if err == nil {
+		t.Fatal("must be failed")
+	}
+	if !strings.Contains(err.Error(), "401") {
+		t.Errorf("error must contain the status code: %s", err)
+	}
+}
+
+func TestReadHTTPBackendTLS(t *testing.T) {
+	t.Setenv("TF_HTTP_BEARER_TOKEN", "mytoken")
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer mytoken" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		http.ServeFile(w, r, "test/terraform.tfstate")
+	}))
+	defer ts.Close()
+
+	file := writeBackendState(t, "http", map[string]interface{}{
+		"address": ts.URL + "/state",
+	}, "")
+	if _, err := tfstate.ReadFile(context.Background(), file); err == nil {
+		t.Error("must be failed with a self-signed certificate")
+	}
+
+	file = writeBackendState(t, "http", map[string]interface{}{
+		"address":                ts.URL + "/state",
+		"skip_cert_verification": true,
+		"timeout":                "5s",
+	}, "")
+	state, err := tfstate.ReadFile(context.Background(), file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testLookupState(t, state)
+}
+
+func TestReadHTTPBackendTimeout(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer ts.Close()
+
+	file := writeBackendState(t, "http", map[string]interface{}{
+		"address": ts.URL + "/state",
+	}, "")
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err := tfstate.ReadFile(ctx, file)
+	if err == nil {
+		t.Fatal("must be timed out")
+	}
+	if !strings.Contains(err.Error(), "timed out reading the state from http backend (address="+ts.URL+"/state)") {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+func TestReadHTTPBackendTimeoutRedacted(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer ts.Close()
+
+	address := strings.Replace(ts.URL, "http://", "http://user:secret@", 1) + "/state"
+	file := writeBackendState(t, "http", map[string]interface{}{
+		"address": address,
+	}, "")
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err := tfstate.ReadFile(ctx, file)
+	if err == nil {
+		t.Fatal("must be timed out")
+	}
+	if !strings.Contains(err.Error(), "http backend (address="+ts.URL+"/state)") {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if strings.Contains(err.Error(), "secret") {
+		t.Errorf("the password must not be included in the error: %s", err)
+	}
+}
+
+func TestReadHTTPBackendCanceled(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("must not be requested")
+	}))
+	defer ts.Close()
+
+	file := writeBackendState(t, "http", map[string]interface{}{
+		"address": ts.URL + "/state",
+	}, "")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := tfstate.ReadFileWithWorkspace(ctx, file, "default")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
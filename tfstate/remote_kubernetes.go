@@ -0,0 +1,105 @@
+package tfstate
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const kubernetesStateKey = "tfstate"
+
+type kubernetesOption struct {
+	namespace       string
+	configPath      string
+	configContext   string
+	inClusterConfig bool
+}
+
+func readKubernetesState(ctx context.Context, config map[string]interface{}, ws string) (io.ReadCloser, error) {
+	suffix := *strpe(config["secret_suffix"])
+	if suffix == "" {
+		return nil, errors.New("secret_suffix is required for kubernetes backend")
+	}
+	opt := kubernetesOption{
+		namespace:       *strpe(config["namespace"]),
+		configPath:      *strpe(config["config_path"]),
+		configContext:   *strpe(config["config_context"]),
+		inClusterConfig: boolv(config["in_cluster_config"]),
+	}
+	if opt.namespace == "" {
+		opt.namespace = os.Getenv("KUBE_NAMESPACE")
+	}
+	if opt.namespace == "" {
+		opt.namespace = "default"
+	}
+	if opt.configPath == "" {
+		opt.configPath = os.Getenv("KUBE_CONFIG_PATH")
+	}
+	name := fmt.Sprintf("tfstate-%s-%s", ws, suffix)
+	return readKubernetes(ctx, name, opt)
+}
+
+func kubernetesRestConfig(opt kubernetesOption) (*rest.Config, error) {
+	// use in-cluster config when running in a pod without an explicit kubeconfig
+	if opt.inClusterConfig || (opt.configPath == "" && os.Getenv("KUBERNETES_SERVICE_HOST") != "") {
+		return rest.InClusterConfig()
+	}
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if opt.configPath != "" {
+		// KUBE_CONFIG_PATH may contain multiple paths like KUBECONFIG
+		var paths []string
+		for _, p := range strings.Split(opt.configPath, string(os.PathListSeparator)) {
+			expanded, err := homedir.Expand(p)
+			if err != nil {
+				return nil, err
+			}
+			paths = append(paths, expanded)
+		}
+		rules.Precedence = paths
+	}
+	overrides := &clientcmd.ConfigOverrides{}
+	if opt.configContext != "" {
+		overrides.CurrentContext = opt.configContext
+	}
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides).ClientConfig()
+}
+
+func readKubernetes(ctx context.Context, name string, opt kubernetesOption) (io.ReadCloser, error) {
+	cfg, err := kubernetesRestConfig(opt)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load kubernetes config")
+	}
+	client, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to setup kubernetes client")
+	}
+	secret, err := client.CoreV1().Secrets(opt.namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, errors.Errorf("secret %s is not found in namespace %s", name, opt.namespace)
+		}
+		return nil, errors.Wrapf(err, "failed to get secret %s in namespace %s", name, opt.namespace)
+	}
+	data, ok := secret.Data[kubernetesStateKey]
+	if !ok {
+		return nil, errors.Errorf("secret %s in namespace %s has no %s key", name, opt.namespace, kubernetesStateKey)
+	}
+	// the state is always gzipped by Terraform
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decompress secret %s in namespace %s", name, opt.namespace)
+	}
+	return r, nil
+}
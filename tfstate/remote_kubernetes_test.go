@@ -0,0 +1,87 @@
+package tfstate_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fujiwara/tfstate-lookup/tfstate"
+)
+
+func TestReadKubernetes(t *testing.T) {
+	b, err := os.ReadFile("test/terraform.tfstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write(b)
+	w.Close()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path != "/api/v1/namespaces/terraform/secrets/tfstate-default-app" {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"kind":"Status","apiVersion":"v1","status":"Failure","reason":"NotFound","code":404}`)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"kind":       "Secret",
+			"apiVersion": "v1",
+			"metadata":   map[string]interface{}{"name": "tfstate-default-app", "namespace": "terraform"},
+			"data":       map[string]interface{}{"tfstate": gz.Bytes()},
+		})
+	}))
+	defer ts.Close()
+
+	kubeconfig := filepath.Join(t.TempDir(), "kubeconfig")
+	if err := os.WriteFile(kubeconfig, []byte(fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: test
+  cluster:
+    server: %s
+contexts:
+- name: test
+  context:
+    cluster: test
+    user: test
+users:
+- name: test
+  user:
+    token: dummy
+current-context: test
+`, ts.URL)), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+
+	file := writeBackendState(t, "kubernetes", map[string]interface{}{
+		"secret_suffix": "app",
+		"namespace":     "terraform",
+		"config_path":   kubeconfig,
+	}, "")
+	state, err := tfstate.ReadFile(context.Background(), file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testLookupState(t, state)
+
+	file = writeBackendState(t, "kubernetes", map[string]interface{}{
+		"secret_suffix": "app",
+		"namespace":     "terraform",
+		"config_path":   kubeconfig,
+	}, "dev")
+	_, err = tfstate.ReadFile(context.Background(), file)
+	if err == nil || !strings.Contains(err.Error(), "tfstate-dev-app") {
+		t.Errorf("error must contain the secret name: %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+package tfstate
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultOSSPrefix = "env:"
+	defaultOSSKey    = "terraform.tfstate"
+)
+
+type ossOption struct {
+	endpoint      string
+	accessKey     string
+	secretKey     string
+	securityToken string
+}
+
+func readOSSState(ctx context.Context, config map[string]interface{}, ws string) (io.ReadCloser, error) {
+	bucket := *strpe(config["bucket"])
+	if bucket == "" {
+		return nil, errors.New("bucket is required for oss backend")
+	}
+	prefix, key := defaultOSSPrefix, defaultOSSKey
+	if p := strp(config["prefix"]); p != nil {
+		prefix = *p
+	}
+	if k := strp(config["key"]); k != nil && *k != "" {
+		key = *k
+	}
+	if ws == defaultWorkspace {
+		key = path.Join(prefix, key)
+	} else {
+		key = path.Join(prefix, ws, key)
+	}
+
+	opt := ossOption{
+		endpoint:      *strpe(config["endpoint"]),
+		accessKey:     *strpe(config["access_key"]),
+		secretKey:     *strpe(config["secret_key"]),
+		securityToken: *strpe(config["security_token"]),
+	}
+	if opt.endpoint == "" {
+		opt.endpoint = os.Getenv("ALICLOUD_OSS_ENDPOINT")
+	}
+	if opt.endpoint == "" {
+		region := *strpe(config["region"])
+		if region == "" {
+			region = os.Getenv("ALICLOUD_REGION")
+		}
+		if region == "" {
+			return nil, errors.New("endpoint or region is required for oss backend")
+		}
+		opt.endpoint = fmt.Sprintf("oss-%s.aliyuncs.com", region)
+	}
+	return readOSS(ctx, bucket, key, opt)
+}
+
+func readOSS(ctx context.Context, bucketName, key string, opt ossOption) (io.ReadCloser, error) {
+	for _, v := range []struct {
+		value *string
+		env   string
+	}{
+		{&opt.accessKey, "ALICLOUD_ACCESS_KEY"},
+		{&opt.secretKey, "ALICLOUD_SECRET_KEY"},
+		{&opt.securityToken, "ALICLOUD_SECURITY_TOKEN"},
+	} {
+		if *v.value == "" {
+			*v.value = os.Getenv(v.env)
+		}
+	}
+
+	var clientOpts []oss.ClientOption
+	if opt.securityToken != "" {
+		clientOpts = append(clientOpts, oss.SecurityToken(opt.securityToken))
+	}
+	client, err := oss.New(opt.endpoint, opt.accessKey, opt.secretKey, clientOpts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to setup oss client")
+	}
+	bucket, err := client.Bucket(bucketName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to access bucket %s", bucketName)
+	}
+	r, err := bucket.GetObject(key, oss.WithContext(ctx))
+	if err != nil {
+		var serr oss.ServiceError
+		if errors.As(err, &serr) && serr.StatusCode == http.StatusNotFound {
+			return nil, errors.Errorf("state object oss://%s/%s is not found", bucketName, key)
+		}
+		return nil, errors.Wrapf(err, "failed to get oss://%s/%s", bucketName, key)
+	}
+	return r, nil
+}
@@ -0,0 +1,53 @@
+package tfstate_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fujiwara/tfstate-lookup/tfstate"
+)
+
+func TestReadOSS(t *testing.T) {
+	t.Setenv("ALICLOUD_ACCESS_KEY", "access")
+	t.Setenv("ALICLOUD_SECRET_KEY", "secret")
+	t.Setenv("ALICLOUD_SECURITY_TOKEN", "token")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Oss-Security-Token") != "token" {
+			t.Errorf("security token is not sent")
+		}
+		switch r.URL.Path {
+		case "/mybucket/env:/terraform.tfstate", "/mybucket/states/dev/app.tfstate":
+			http.ServeFile(w, r, "test/terraform.tfstate")
+		default:
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?><Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>`))
+		}
+	}))
+	defer ts.Close()
+
+	for _, c := range []struct {
+		ws     string
+		config map[string]interface{}
+	}{
+		{"", map[string]interface{}{"bucket": "mybucket", "endpoint": ts.URL}},
+		{"dev", map[string]interface{}{"bucket": "mybucket", "endpoint": ts.URL, "prefix": "states", "key": "app.tfstate"}},
+	} {
+		file := writeBackendState(t, "oss", c.config, c.ws)
+		state, err := tfstate.ReadFile(context.Background(), file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		testLookupState(t, state)
+	}
+
+	file := writeBackendState(t, "oss", map[string]interface{}{"bucket": "mybucket", "endpoint": ts.URL}, "prod")
+	_, err := tfstate.ReadFile(context.Background(), file)
+	if err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,51 @@
+package tfstate
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+const defaultPGSchemaName = "terraform_remote_state"
+
+func readPGState(ctx context.Context, config map[string]interface{}, ws string) (io.ReadCloser, error) {
+	connStr, schemaName := *strpe(config["conn_str"]), *strpe(config["schema_name"])
+	if connStr == "" {
+		connStr = os.Getenv("PG_CONN_STR")
+	}
+	if connStr == "" {
+		return nil, errors.New("conn_str is required for pg backend")
+	}
+	if schemaName == "" {
+		schemaName = os.Getenv("PG_SCHEMA_NAME")
+	}
+	if schemaName == "" {
+		schemaName = defaultPGSchemaName
+	}
+	// pg backend uses the workspace name as is (not env: prefixed)
+	return readPG(ctx, connStr, schemaName, ws)
+}
+
+func readPG(ctx context.Context, connStr, schemaName, name string) (io.ReadCloser, error) {
+	db, err := sql.Open("postgres", connStr)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open postgres connection")
+	}
+	defer db.Close()
+
+	query := fmt.Sprintf(`SELECT data FROM %s.states WHERE name = $1`, pq.QuoteIdentifier(schemaName))
+	var data []byte
+	if err := db.QueryRowContext(ctx, query, name).Scan(&data); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.Errorf("state %s is not found in %s.states", name, schemaName)
+		}
+		return nil, errors.Wrap(err, "failed to query state")
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
@@ -0,0 +1,70 @@
+package tfstate_test
+
+import (
+	"context"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/fujiwara/tfstate-lookup/tfstate"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRegisterBackend(t *testing.T) {
+	var got string
+	tfstate.RegisterBackend("vault", func(ctx context.Context, config map[string]interface{}, ws string) (io.ReadCloser, error) {
+		got = config["path"].(string) + ":" + ws
+		return os.Open("test/terraform.tfstate")
+	})
+	file := writeBackendState(t, "vault", map[string]interface{}{
+		"path": "secret/tfstate",
+	}, "dev")
+	state, err := tfstate.ReadFile(context.Background(), file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testLookupState(t, state)
+	if got != "secret/tfstate:dev" {
+		t.Errorf("unexpected config and workspace %s", got)
+	}
+
+	file = writeBackendState(t, "unknown", map[string]interface{}{}, "")
+	if _, err := tfstate.ReadFile(context.Background(), file); err == nil {
+		t.Error("must be failed for unsupported backend")
+	}
+}
+
+func TestBackend(t *testing.T) {
+	tfstate.RegisterBackend("inspect", func(ctx context.Context, config map[string]interface{}, ws string) (io.ReadCloser, error) {
+		return os.Open("test/terraform.tfstate")
+	})
+	file := writeBackendState(t, "inspect", map[string]interface{}{
+		"bucket":           "mybucket",
+		"force_path_style": true,
+		"endpoints":        map[string]interface{}{"s3": "http://localhost:9000"},
+	}, "")
+	state, err := tfstate.ReadFile(context.Background(), file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	typ, config, ok := state.Backend()
+	if !ok || typ != "inspect" {
+		t.Errorf("unexpected backend %s %v", typ, ok)
+	}
+	expected := map[string]string{
+		"bucket":           "mybucket",
+		"force_path_style": "true",
+		"endpoints":        `{"s3":"http://localhost:9000"}`,
+	}
+	if diff := cmp.Diff(config, expected); diff != "" {
+		t.Errorf("unexpected config %s", diff)
+	}
+
+	local, err := tfstate.ReadFile(context.Background(), "test/terraform.tfstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok := local.Backend(); ok {
+		t.Error("a local state must not have a backend")
+	}
+}
@@ -2,50 +2,221 @@ package tfstate
 
 import (
 	"context"
+	"crypto/md5"
+	"encoding/base64"
 	"io"
+	"os"
 	"path"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/aws/arn"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/credentials/ssocreds"
 	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
 	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
+	"github.com/pkg/errors"
 )
 
+const defaultS3Region = "us-east-1"
+
 type s3Option struct {
-	region   string
-	role_arn string
+	region         string
+	profile        string
+	role_arn       string
+	externalID     string
+	sessionName    string
+	endpoint       string
+	stsEndpoint    string
+	sseCustomerKey string
+
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+
+	usePathStyle              bool
+	skipCredentialsValidation bool
+	skipRegionValidation      bool
+}
+
+// s3Key returns the object key of the workspace as Terraform does.
+// The default workspace uses the key as is, other workspaces use <workspace_key_prefix>/<workspace>/<key>.
+func s3Key(config map[string]interface{}, ws string) string {
+	key := *strpe(config["key"])
+	if ws == defaultWorkspace {
+		return key
+	}
+	if prefix := strp(config["workspace_key_prefix"]); prefix != nil {
+		return path.Join(*prefix, ws, key)
+	}
+	return path.Join(defaultWorkspeceKeyPrefix, ws, key)
 }
 
 func readS3State(ctx context.Context, config map[string]interface{}, ws string) (io.ReadCloser, error) {
-	bucket, key := *strpe(config["bucket"]), *strpe(config["key"])
-	if ws != defaultWorkspace {
-		if prefix := strp(config["workspace_key_prefix"]); prefix != nil {
-			key = path.Join(*prefix, ws, key)
-		} else {
-			key = path.Join(defaultWorkspeceKeyPrefix, ws, key)
-		}
+	bucket, key := *strpe(config["bucket"]), s3Key(config, ws)
+	opt, err := s3OptionFromConfig(ctx, config)
+	if err != nil {
+		return nil, err
 	}
+	loggerFrom(ctx).Debug("s3 object", "bucket", bucket, "key", key, "region", opt.region, "profile", opt.profile, "role_arn", opt.role_arn, "endpoint", opt.endpoint)
+	return readS3(ctx, bucket, key, opt)
+}
+
+func s3OptionFromConfig(ctx context.Context, config map[string]interface{}) (s3Option, error) {
 	opt := s3Option{
-		region:   *strpe(config["region"]),
-		role_arn: *strpe(config["role_arn"]),
+		region:                    *strpe(config["region"]),
+		profile:                   *strpe(config["profile"]),
+		role_arn:                  *strpe(config["role_arn"]),
+		endpoint:                  *strpe(config["endpoint"]),
+		externalID:                *strpe(config["external_id"]),
+		sessionName:               *strpe(config["session_name"]),
+		stsEndpoint:               *strpe(config["sts_endpoint"]),
+		usePathStyle:              boolv(config["force_path_style"]) || boolv(config["use_path_style"]),
+		skipCredentialsValidation: boolv(config["skip_credentials_validation"]),
+		skipRegionValidation:      boolv(config["skip_region_validation"]),
+		sseCustomerKey:            *strpe(config["sse_customer_key"]),
 	}
-	return readS3(ctx, bucket, key, opt)
+	if endpoints, ok := config["endpoints"].(map[string]interface{}); ok {
+		if opt.endpoint == "" {
+			opt.endpoint = *strpe(endpoints["s3"])
+		}
+		if opt.stsEndpoint == "" {
+			opt.stsEndpoint = *strpe(endpoints["sts"])
+		}
+	}
+	if assumeRole, ok := config["assume_role"].(map[string]interface{}); ok {
+		opt.role_arn = *strpe(assumeRole["role_arn"])
+		opt.externalID = *strpe(assumeRole["external_id"])
+		opt.sessionName = *strpe(assumeRole["session_name"])
+	}
+	if opt.sseCustomerKey == "" {
+		opt.sseCustomerKey = os.Getenv("AWS_SSE_CUSTOMER_KEY")
+	}
+	creds, err := credentialsFor(ctx, "s3", config)
+	if err != nil {
+		return opt, err
+	}
+	opt.accessKeyID, opt.secretAccessKey, opt.sessionToken = creds.AccessKeyID, creds.SecretAccessKey, creds.SessionToken
+	return opt, nil
+}
+
+// listS3Workspaces lists workspaces which have the state object at <workspace_key_prefix>/<workspace>/<key>.
+func listS3Workspaces(ctx context.Context, config map[string]interface{}) ([]string, error) {
+	bucket, key := *strpe(config["bucket"]), *strpe(config["key"])
+	opt, err := s3OptionFromConfig(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	svc, err := newS3Client(ctx, bucket, opt)
+	if err != nil {
+		return nil, err
+	}
+	prefix := defaultWorkspeceKeyPrefix
+	if p := strp(config["workspace_key_prefix"]); p != nil {
+		prefix = *p
+	}
+	prefix = strings.TrimSuffix(prefix, "/") + "/"
+	workspaces := []string{defaultWorkspace}
+	p := s3.NewListObjectsV2Paginator(svc, &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	})
+	for p.HasMorePages() {
+		page, err := p.NextPage(ctx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list objects in s3://%s/%s", bucket, prefix)
+		}
+		for _, obj := range page.Contents {
+			ws, rest, ok := strings.Cut(strings.TrimPrefix(aws.ToString(obj.Key), prefix), "/")
+			if ok && rest == key && ws != "" {
+				workspaces = append(workspaces, ws)
+			}
+		}
+	}
+	return workspaces, nil
 }
 
 func readS3(ctx context.Context, bucket, key string, opt s3Option) (io.ReadCloser, error) {
-	cfg, err := config.LoadDefaultConfig(ctx,
+	svc, err := newS3Client(ctx, bucket, opt)
+	if err != nil {
+		return nil, err
+	}
+	// SSE-S3 and SSE-KMS encrypted objects are decrypted by S3 transparently.
+	// SSE-C requires the customer provided key for each request.
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	}
+	if opt.sseCustomerKey != "" {
+		b, err := base64.StdEncoding.DecodeString(opt.sseCustomerKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid sse_customer_key")
+		}
+		sum := md5.Sum(b)
+		input.SSECustomerAlgorithm = aws.String("AES256")
+		input.SSECustomerKey = aws.String(opt.sseCustomerKey)
+		input.SSECustomerKeyMD5 = aws.String(base64.StdEncoding.EncodeToString(sum[:]))
+	}
+	result, err := svc.GetObject(ctx, input)
+	if err != nil {
+		var apiErr smithy.APIError
+		var tokenErr *ssocreds.InvalidTokenError
+		if errors.As(err, &tokenErr) {
+			profile := opt.profile
+			if profile == "" {
+				profile = os.Getenv("AWS_PROFILE")
+			}
+			return nil, errors.Wrapf(err, "SSO session for profile %s is invalid or expired, run `aws sso login --profile %s`", profile, profile)
+		}
+		if errors.As(err, &apiErr) && strings.Contains(strings.ToLower(apiErr.ErrorCode()+" "+apiErr.ErrorMessage()), "kms") {
+			return nil, errors.Wrapf(err, "failed to decrypt s3://%s/%s by KMS (kms:Decrypt permission is required)", bucket, key)
+		}
+		return nil, err
+	}
+	return result.Body, nil
+}
+
+// newS3Client returns a client for the bucket, resolving the region and credentials.
+func newS3Client(ctx context.Context, bucket string, opt s3Option) (*s3.Client, error) {
+	optFns := []func(*config.LoadOptions) error{
 		config.WithRegion(opt.region),
-	)
+	}
+	if opt.profile != "" {
+		optFns = append(optFns, config.WithSharedConfigProfile(opt.profile))
+	}
+	if opt.accessKeyID != "" {
+		// static credentials from CredentialProvider are used instead of the default chain
+		optFns = append(optFns, config.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(opt.accessKeyID, opt.secretAccessKey, opt.sessionToken),
+		))
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, optFns...)
 	if err != nil {
 		return nil, err
 	}
 	if opt.region == "" {
-		opt.region, err = getBucketRegion(ctx, cfg, bucket)
-		if err != nil {
-			return nil, err
+		if opt.skipRegionValidation || opt.endpoint != "" {
+			// S3 compatible stores don't know about AWS regions
+			cfg.Region = defaultS3Region
+		} else {
+			region, err := getBucketRegion(ctx, cfg, bucket)
+			if err != nil {
+				return nil, err
+			}
+			cfg.Region = region
+			loggerFrom(ctx).Debug("resolved the bucket region", "bucket", bucket, "region", region)
+		}
+	}
+	if opt.skipCredentialsValidation {
+		// fallback to anonymous access for S3 compatible stores without credentials
+		if cfg.Credentials == nil {
+			cfg.Credentials = aws.AnonymousCredentials{}
+		} else if _, err := cfg.Credentials.Retrieve(ctx); err != nil {
+			cfg.Credentials = aws.AnonymousCredentials{}
 		}
 	}
 	if opt.role_arn != "" {
@@ -53,18 +224,28 @@ func readS3(ctx context.Context, bucket, key string, opt s3Option) (io.ReadClose
 		if err != nil {
 			return nil, err
 		}
-		creds := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(cfg), arn.String())
-		cfg.Credentials = creds
+		// assume the role by the base credentials (resolved from the profile, env vars or so on)
+		stsClient := sts.NewFromConfig(cfg, func(o *sts.Options) {
+			if opt.stsEndpoint != "" {
+				o.EndpointResolver = sts.EndpointResolverFromURL(opt.stsEndpoint)
+			}
+		})
+		creds := stscreds.NewAssumeRoleProvider(stsClient, arn.String(), func(o *stscreds.AssumeRoleOptions) {
+			if opt.externalID != "" {
+				o.ExternalID = aws.String(opt.externalID)
+			}
+			if opt.sessionName != "" {
+				o.RoleSessionName = opt.sessionName
+			}
+		})
+		cfg.Credentials = aws.NewCredentialsCache(creds)
 	}
-	svc := s3.NewFromConfig(cfg)
-	result, err := svc.GetObject(ctx, &s3.GetObjectInput{
-		Bucket: aws.String(bucket),
-		Key:    aws.String(key),
-	})
-	if err != nil {
-		return nil, err
-	}
-	return result.Body, nil
+	return s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if opt.endpoint != "" {
+			o.EndpointResolver = s3.EndpointResolverFromURL(opt.endpoint)
+		}
+		o.UsePathStyle = opt.usePathStyle
+	}), nil
 }
 
 func getBucketRegion(ctx context.Context, cfg aws.Config, bucket string) (string, error) {
@@ -1,6 +1,17 @@
 package tfstate_test
 
 import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/fujiwara/tfstate-lookup/tfstate"
@@ -31,3 +42,369 @@ func TestBucketRegion(t *testing.T) {
 		}
 	}
 }
+
+func TestReadS3Endpoint(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "minio")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "minio123")
+	t.Setenv("AWS_CONFIG_FILE", os.DevNull)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", os.DevNull)
+	t.Setenv("AWS_PROFILE", "")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/mybucket/path/to/terraform.tfstate" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeFile(w, r, "test/terraform.tfstate")
+	}))
+	defer ts.Close()
+
+	file := writeBackendState(t, "s3", map[string]interface{}{
+		"bucket":                      "mybucket",
+		"key":                         "path/to/terraform.tfstate",
+		"endpoint":                    ts.URL,
+		"force_path_style":            true,
+		"skip_credentials_validation": true,
+		"skip_region_validation":      true,
+	}, "")
+	state, err := tfstate.ReadFile(context.Background(), file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testLookupState(t, state)
+}
+
+func TestReadS3SSECustomerKey(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "minio")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "minio123")
+	t.Setenv("AWS_CONFIG_FILE", os.DevNull)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", os.DevNull)
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_SSE_CUSTOMER_KEY", base64.StdEncoding.EncodeToString(bytes.Repeat([]byte("e"), 32)))
+
+	key := bytes.Repeat([]byte("c"), 32)
+	sum := md5.Sum(key)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for name, expected := range map[string]string{
+			"X-Amz-Server-Side-Encryption-Customer-Algorithm": "AES256",
+			"X-Amz-Server-Side-Encryption-Customer-Key":       base64.StdEncoding.EncodeToString(key),
+			"X-Amz-Server-Side-Encryption-Customer-Key-Md5":   base64.StdEncoding.EncodeToString(sum[:]),
+		} {
+			if v := r.Header.Get(name); v != expected {
+				t.Errorf("unexpected %s header %q, expected %q", name, v, expected)
+			}
+		}
+		http.ServeFile(w, r, "test/terraform.tfstate")
+	}))
+	defer ts.Close()
+
+	file := writeBackendState(t, "s3", map[string]interface{}{
+		"bucket":                      "mybucket",
+		"key":                         "path/to/terraform.tfstate",
+		"endpoint":                    ts.URL,
+		"force_path_style":            true,
+		"skip_credentials_validation": true,
+		"skip_region_validation":      true,
+		"sse_customer_key":            base64.StdEncoding.EncodeToString(key),
+	}, "")
+	state, err := tfstate.ReadFile(context.Background(), file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testLookupState(t, state)
+}
+
+func TestReadS3URL(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "minio")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "minio123")
+	t.Setenv("AWS_CONFIG_FILE", os.DevNull)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", os.DevNull)
+	t.Setenv("AWS_PROFILE", "")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/mybucket/path/to/terraform.tfstate", "/mybucket/env:/dev/path/to/terraform.tfstate":
+			http.ServeFile(w, r, "test/terraform.tfstate")
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	loc := fmt.Sprintf("s3://mybucket/path/to/terraform.tfstate?region=ap-northeast-1&endpoint=%s&use_path_style=true", ts.URL)
+	state, err := tfstate.ReadURL(context.Background(), loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testLookupState(t, state)
+
+	state, err = tfstate.ReadURLWithWorkspace(context.Background(), loc, "dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testLookupState(t, state)
+}
+
+func TestReadURLWithWorkspaceNotSupported(t *testing.T) {
+	for _, loc := range []string{"https://example.com/terraform.tfstate", "gs://mybucket/terraform.tfstate"} {
+		if _, err := tfstate.ReadURLWithWorkspace(context.Background(), loc, "dev"); !errors.Is(err, tfstate.ErrWorkspaceNotSupported) {
+			t.Errorf("%s: unexpected error %v", loc, err)
+		}
+	}
+}
+
+func TestReadS3AssumeRole(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIABASE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "base-secret")
+	t.Setenv("AWS_CONFIG_FILE", os.DevNull)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", os.DevNull)
+	t.Setenv("AWS_PROFILE", "")
+
+	var assumed bool
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if r.Method == http.MethodPost {
+			r.ParseForm()
+			if r.Form.Get("Action") != "AssumeRole" || !strings.Contains(auth, "AKIABASE") {
+				t.Errorf("unexpected sts request %s by %s", r.Form.Get("Action"), auth)
+			}
+			if r.Form.Get("RoleArn") != "arn:aws:iam::123456789012:role/tfstate" || r.Form.Get("ExternalId") != "ext" || r.Form.Get("RoleSessionName") != "tfstate-lookup" {
+				t.Errorf("unexpected assume role parameters %v", r.Form)
+			}
+			assumed = true
+			w.Header().Set("Content-Type", "text/xml")
+			fmt.Fprint(w, `<AssumeRoleResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/"><AssumeRoleResult><Credentials><AccessKeyId>ASIATEMP</AccessKeyId><SecretAccessKey>temp-secret</SecretAccessKey><SessionToken>temp-token</SessionToken><Expiration>2099-01-01T00:00:00Z</Expiration></Credentials><AssumedRoleUser><Arn>arn:aws:sts::123456789012:assumed-role/tfstate/tfstate-lookup</Arn><AssumedRoleId>AROA:tfstate-lookup</AssumedRoleId></AssumedRoleUser></AssumeRoleResult><ResponseMetadata><RequestId>x</RequestId></ResponseMetadata></AssumeRoleResponse>`)
+			return
+		}
+		if !assumed || !strings.Contains(auth, "ASIATEMP") {
+			t.Errorf("GetObject must be called by the assumed role: %s", auth)
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		http.ServeFile(w, r, "test/terraform.tfstate")
+	}))
+	defer ts.Close()
+
+	file := writeBackendState(t, "s3", map[string]interface{}{
+		"bucket":         "mybucket",
+		"key":            "terraform.tfstate",
+		"region":         "us-east-1",
+		"use_path_style": true,
+		"endpoints": map[string]interface{}{
+			"s3":  ts.URL,
+			"sts": ts.URL,
+		},
+		"assume_role": map[string]interface{}{
+			"role_arn":     "arn:aws:iam::123456789012:role/tfstate",
+			"external_id":  "ext",
+			"session_name": "tfstate-lookup",
+		},
+	}, "")
+	state, err := tfstate.ReadFile(context.Background(), file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testLookupState(t, state)
+}
+
+func TestReadS3KMSDenied(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIABASE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "base-secret")
+	t.Setenv("AWS_CONFIG_FILE", os.DevNull)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", os.DevNull)
+	t.Setenv("AWS_PROFILE", "")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>AccessDenied</Code><Message>User: arn:aws:iam::123456789012:user/me is not authorized to perform: kms:Decrypt on resource: arn:aws:kms:us-east-1:123456789012:key/abcd</Message></Error>`)
+	}))
+	defer ts.Close()
+
+	file := writeBackendState(t, "s3", map[string]interface{}{
+		"bucket":         "mybucket",
+		"key":            "terraform.tfstate",
+		"region":         "us-east-1",
+		"endpoint":       ts.URL,
+		"use_path_style": true,
+	}, "")
+	_, err := tfstate.ReadFile(context.Background(), file)
+	if err == nil {
+		t.Fatal("must be failed")
+	}
+	if !strings.Contains(err.Error(), "KMS") {
+		t.Errorf("error must mention KMS: %s", err)
+	}
+}
+
+var testS3Keys = []struct {
+	config map[string]interface{}
+	ws     string
+	key    string
+}{
+	{
+		config: map[string]interface{}{"key": "path/to/terraform.tfstate"},
+		ws:     "default",
+		key:    "path/to/terraform.tfstate",
+	},
+	{
+		config: map[string]interface{}{"key": "path/to/terraform.tfstate"},
+		ws:     "dev",
+		key:    "env:/dev/path/to/terraform.tfstate",
+	},
+	{
+		config: map[string]interface{}{"key": "path/to/terraform.tfstate", "workspace_key_prefix": "workspaces"},
+		ws:     "dev",
+		key:    "workspaces/dev/path/to/terraform.tfstate",
+	},
+	{
+		config: map[string]interface{}{"key": "path/to/terraform.tfstate", "workspace_key_prefix": "workspaces"},
+		ws:     "default",
+		key:    "path/to/terraform.tfstate",
+	},
+}
+
+func TestS3Key(t *testing.T) {
+	for _, c := range testS3Keys {
+		if key := tfstate.S3Key(c.config, c.ws); key != c.key {
+			t.Errorf("unexpected key for workspace %s. expected %s, got %s", c.ws, c.key, key)
+		}
+	}
+}
+
+func TestReadS3SSOProfile(t *testing.T) {
+	home := t.TempDir()
+	awsConfig := filepath.Join(home, "config")
+	if err := os.WriteFile(awsConfig, []byte(`[profile sso]
+sso_start_url = https://example.awsapps.com/start
+sso_region = us-east-1
+sso_account_id = 123456789012
+sso_role_name = ReadOnly
+region = us-east-1
+`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HOME", home)
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	t.Setenv("AWS_CONFIG_FILE", awsConfig)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", os.DevNull)
+	t.Setenv("AWS_PROFILE", "sso")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("must not be called without valid SSO session")
+	}))
+	defer ts.Close()
+
+	// profile is resolved from AWS_PROFILE
+	file := writeBackendState(t, "s3", map[string]interface{}{
+		"bucket":         "mybucket",
+		"key":            "terraform.tfstate",
+		"endpoint":       ts.URL,
+		"use_path_style": true,
+	}, "")
+	_, err := tfstate.ReadFile(context.Background(), file)
+	if err == nil || !strings.Contains(err.Error(), "aws sso login --profile sso") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestReadS3CredentialProvider(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "minio")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "minio123")
+	t.Setenv("AWS_CONFIG_FILE", os.DevNull)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", os.DevNull)
+	t.Setenv("AWS_PROFILE", "")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if auth := r.Header.Get("Authorization"); !strings.Contains(auth, "Credential=injected/") {
+			t.Errorf("credentials of the provider are not used: %s", auth)
+		}
+		http.ServeFile(w, r, "test/terraform.tfstate")
+	}))
+	defer ts.Close()
+
+	file := writeBackendState(t, "s3", map[string]interface{}{
+		"bucket":           "mybucket",
+		"key":              "path/to/terraform.tfstate",
+		"endpoint":         ts.URL,
+		"force_path_style": true,
+	}, "")
+	var backendType string
+	provider := tfstate.CredentialProviderFunc(func(ctx context.Context, typ string, config map[string]interface{}) (*tfstate.Credentials, error) {
+		backendType = typ
+		return &tfstate.Credentials{AccessKeyID: "injected", SecretAccessKey: "secret"}, nil
+	})
+	state, err := tfstate.ReadFileWithOptions(file, tfstate.WithCredentialProvider(provider))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testLookupState(t, state)
+	if backendType != "s3" {
+		t.Errorf("unexpected backend type %s", backendType)
+	}
+
+	failing := tfstate.CredentialProviderFunc(func(ctx context.Context, typ string, config map[string]interface{}) (*tfstate.Credentials, error) {
+		return nil, fmt.Errorf("vault is sealed")
+	})
+	if _, err := tfstate.ReadFileWithOptions(file, tfstate.WithCredentialProvider(failing)); err == nil || !strings.Contains(err.Error(), "vault is sealed") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestS3Workspaces(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "minio")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "minio123")
+	t.Setenv("AWS_CONFIG_FILE", os.DevNull)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", os.DevNull)
+	t.Setenv("AWS_PROFILE", "")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("list-type") == "2" {
+			if prefix := r.URL.Query().Get("prefix"); prefix != "env:/" {
+				t.Errorf("unexpected prefix %s", prefix)
+			}
+			w.Header().Set("Content-Type", "application/xml")
+			fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?>
+<ListBucketResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <Name>mybucket</Name>
+  <Prefix>env:/</Prefix>
+  <IsTruncated>false</IsTruncated>
+  <Contents><Key>env:/staging/path/to/terraform.tfstate</Key></Contents>
+  <Contents><Key>env:/dev/path/to/terraform.tfstate</Key></Contents>
+  <Contents><Key>env:/dev/other/terraform.tfstate</Key></Contents>
+</ListBucketResult>`)
+			return
+		}
+		http.ServeFile(w, r, "test/terraform.tfstate")
+	}))
+	defer ts.Close()
+
+	file := writeBackendState(t, "s3", map[string]interface{}{
+		"bucket":           "mybucket",
+		"key":              "path/to/terraform.tfstate",
+		"endpoint":         ts.URL,
+		"force_path_style": true,
+	}, "")
+	state, err := tfstate.ReadFile(context.Background(), file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	workspaces, err := state.Workspaces()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := strings.Join(workspaces, ","); s != "default,dev,staging" {
+		t.Errorf("unexpected workspaces %s", s)
+	}
+
+	local, err := tfstate.ReadFile(context.Background(), "test/terraform.tfstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := local.Workspaces(); err == nil {
+		t.Error("expected an error for a state without a backend")
+	}
+}
@@ -0,0 +1,104 @@
+package tfstate
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack"
+	"github.com/gophercloud/gophercloud/openstack/objectstorage/v1/objects"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultSwiftStateName = "tfstate.tf"
+	swiftObjectEnvPrefix  = "env-"
+)
+
+type swiftOption struct {
+	authOptions gophercloud.AuthOptions
+	regionName  string
+}
+
+// swiftConfigValue returns a value of the key in config, or a value of the first environment variable which is set.
+func swiftConfigValue(config map[string]interface{}, key string, envs ...string) string {
+	if v := *strpe(config[key]); v != "" {
+		return v
+	}
+	for _, env := range envs {
+		if v := os.Getenv(env); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func readSwiftState(ctx context.Context, config map[string]interface{}, ws string) (io.ReadCloser, error) {
+	container := swiftConfigValue(config, "container")
+	if container == "" {
+		// path is a deprecated alias of container
+		container = swiftConfigValue(config, "path")
+	}
+	if container == "" {
+		return nil, errors.New("container is required for swift backend")
+	}
+	stateName := swiftConfigValue(config, "state_name")
+	if stateName == "" {
+		stateName = defaultSwiftStateName
+	}
+	name := stateName
+	if ws != defaultWorkspace {
+		name = swiftObjectEnvPrefix + ws + "/" + stateName
+	}
+
+	opt := swiftOption{
+		authOptions: gophercloud.AuthOptions{
+			IdentityEndpoint:            swiftConfigValue(config, "auth_url", "OS_AUTH_URL"),
+			Username:                    swiftConfigValue(config, "user_name", "OS_USERNAME"),
+			UserID:                      swiftConfigValue(config, "user_id", "OS_USER_ID"),
+			Password:                    swiftConfigValue(config, "password", "OS_PASSWORD"),
+			TokenID:                     swiftConfigValue(config, "token", "OS_TOKEN", "OS_AUTH_TOKEN"),
+			TenantID:                    swiftConfigValue(config, "tenant_id", "OS_TENANT_ID", "OS_PROJECT_ID"),
+			TenantName:                  swiftConfigValue(config, "tenant_name", "OS_TENANT_NAME", "OS_PROJECT_NAME"),
+			DomainID:                    swiftConfigValue(config, "domain_id", "OS_USER_DOMAIN_ID", "OS_PROJECT_DOMAIN_ID", "OS_DOMAIN_ID"),
+			DomainName:                  swiftConfigValue(config, "domain_name", "OS_USER_DOMAIN_NAME", "OS_PROJECT_DOMAIN_NAME", "OS_DOMAIN_NAME"),
+			ApplicationCredentialID:     swiftConfigValue(config, "application_credential_id", "OS_APPLICATION_CREDENTIAL_ID"),
+			ApplicationCredentialName:   swiftConfigValue(config, "application_credential_name", "OS_APPLICATION_CREDENTIAL_NAME"),
+			ApplicationCredentialSecret: swiftConfigValue(config, "application_credential_secret", "OS_APPLICATION_CREDENTIAL_SECRET"),
+		},
+		regionName: swiftConfigValue(config, "region_name", "OS_REGION_NAME"),
+	}
+	return readSwift(ctx, container, name, opt)
+}
+
+func readSwift(ctx context.Context, container, name string, opt swiftOption) (io.ReadCloser, error) {
+	if opt.authOptions.IdentityEndpoint == "" {
+		return nil, errors.New("auth_url or OS_AUTH_URL is required for swift backend")
+	}
+	provider, err := openstack.NewClient(opt.authOptions.IdentityEndpoint)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to setup openstack client")
+	}
+	provider.Context = ctx
+	if err := openstack.Authenticate(provider, opt.authOptions); err != nil {
+		if errors.As(err, &gophercloud.ErrDefault401{}) {
+			return nil, errors.Errorf("keystone authentication failed for %s: invalid credentials", opt.authOptions.IdentityEndpoint)
+		}
+		return nil, errors.Wrapf(err, "keystone authentication failed for %s", opt.authOptions.IdentityEndpoint)
+	}
+	client, err := openstack.NewObjectStorageV1(provider, gophercloud.EndpointOpts{
+		Region: opt.regionName,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find object storage endpoint")
+	}
+	res := objects.Download(client, container, name, nil)
+	if res.Err != nil {
+		if errors.As(res.Err, &gophercloud.ErrDefault404{}) {
+			return nil, errors.Errorf("state object %s is not found in container %s", name, container)
+		}
+		return nil, errors.Wrapf(res.Err, "failed to download %s from container %s", name, container)
+	}
+	return res.Body, nil
+}
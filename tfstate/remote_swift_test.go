@@ -0,0 +1,75 @@
+package tfstate_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fujiwara/tfstate-lookup/tfstate"
+)
+
+func newSwiftServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	ts := httptest.NewServer(mux)
+	mux.HandleFunc("/v3/auth/tokens", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if !strings.Contains(string(body), `"password":"secret"`) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("X-Subject-Token", "mytoken")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"token":{"expires_at":"2099-01-01T00:00:00.000000Z","catalog":[{"type":"object-store","name":"swift","endpoints":[{"interface":"public","region":"RegionOne","region_id":"RegionOne","url":"%s/v1/AUTH_test"}]}]}}`, ts.URL)
+	})
+	mux.HandleFunc("/v1/AUTH_test/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Auth-Token") != "mytoken" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/AUTH_test/tfstate/tfstate.tf", "/v1/AUTH_test/tfstate/env-dev/tfstate.tf":
+			http.ServeFile(w, r, "test/terraform.tfstate")
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	return ts
+}
+
+func TestReadSwift(t *testing.T) {
+	ts := newSwiftServer(t)
+	defer ts.Close()
+
+	config := map[string]interface{}{
+		"container":   "tfstate",
+		"auth_url":    ts.URL + "/v3",
+		"user_name":   "admin",
+		"password":    "secret",
+		"domain_name": "Default",
+		"region_name": "RegionOne",
+	}
+	for _, ws := range []string{"", "dev"} {
+		file := writeBackendState(t, "swift", config, ws)
+		state, err := tfstate.ReadFile(context.Background(), file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		testLookupState(t, state)
+	}
+
+	config["password"] = "invalid"
+	file := writeBackendState(t, "swift", config, "")
+	_, err := tfstate.ReadFile(context.Background(), file)
+	if err == nil || !strings.Contains(err.Error(), "keystone authentication failed") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
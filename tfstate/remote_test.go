@@ -0,0 +1,34 @@
+package tfstate_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeBackendState writes a local tfstate which points to the remote backend, and returns the path of it.
+func writeBackendState(t *testing.T, backendType string, config map[string]interface{}, ws string) string {
+	t.Helper()
+	dir := t.TempDir()
+	b, err := json.Marshal(map[string]interface{}{
+		"version": 3,
+		"backend": map[string]interface{}{
+			"type":   backendType,
+			"config": config,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "terraform.tfstate")
+	if err := os.WriteFile(file, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if ws != "" {
+		if err := os.WriteFile(filepath.Join(dir, "environment"), []byte(ws), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return file
+}
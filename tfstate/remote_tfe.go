@@ -2,14 +2,27 @@ package tfstate
 
 import (
 	"context"
+	"encoding/json"
 	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 
 	tfe "github.com/hashicorp/go-tfe"
 	"github.com/pkg/errors"
 )
 
 func readTFEState(ctx context.Context, config map[string]interface{}, ws string) (io.ReadCloser, error) {
-	hostname, organization, token := *strpe(config["hostname"]), *strp(config["organization"]), *strpe(config["token"])
+	hostname, organization, token := *strpe(config["hostname"]), *strpe(config["organization"]), *strpe(config["token"])
+	// cloud block can be configured by environment variables
+	if hostname == "" {
+		hostname = os.Getenv("TF_CLOUD_HOSTNAME")
+	}
+	if organization == "" {
+		organization = os.Getenv("TF_CLOUD_ORGANIZATION")
+	}
 
 	workspaces, ok := config["workspaces"].(map[string]interface{})
 	if !ok {
@@ -18,21 +31,66 @@ func readTFEState(ctx context.Context, config map[string]interface{}, ws string)
 
 	name, prefix := *strpe(workspaces["name"]), *strpe(workspaces["prefix"])
 	if name != "" {
-		return readTFE(ctx, hostname, organization, name, token)
+		return readTFE(ctx, tfeAddress(hostname), organization, name, token)
 	}
 
 	if prefix != "" {
-		return readTFE(ctx, hostname, organization, prefix+ws, token)
+		return readTFE(ctx, tfeAddress(hostname), organization, prefix+ws, token)
 	}
 
-	return nil, errors.Errorf("workspaces requires either name or prefix")
+	// cloud block with tags maps the workspace name as is
+	if workspaces["tags"] != nil {
+		return readTFE(ctx, tfeAddress(hostname), organization, ws, token)
+	}
+
+	return nil, errors.Errorf("workspaces requires either name, prefix or tags")
+}
+
+func tfeAddress(hostname string) string {
+	if hostname == "" {
+		return tfe.DefaultAddress
+	}
+	return "https://" + hostname
 }
 
-func readTFE(ctx context.Context, hostname string, organization string, ws string, token string) (io.ReadCloser, error) {
-	var address string
-	address = tfe.DefaultAddress
-	if hostname != "" {
-		address = "https://" + hostname
+// tfeCredentialsToken returns a token for the hostname from TF_TOKEN_* environment variable or the CLI config file, like Terraform does.
+func tfeCredentialsToken(hostname string) string {
+	// app.terraform.io -> TF_TOKEN_app_terraform_io, tfe-1.example.com -> TF_TOKEN_tfe__1_example_com
+	envName := "TF_TOKEN_" + strings.NewReplacer(".", "_", "-", "__").Replace(hostname)
+	if token := os.Getenv(envName); token != "" {
+		return token
+	}
+
+	var file string
+	if runtime.GOOS == "windows" {
+		file = filepath.Join(os.Getenv("APPDATA"), "terraform.d", "credentials.tfrc.json")
+	} else if dir, err := os.UserHomeDir(); err == nil {
+		file = filepath.Join(dir, ".terraform.d", "credentials.tfrc.json")
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	var creds struct {
+		Credentials map[string]struct {
+			Token string `json:"token"`
+		} `json:"credentials"`
+	}
+	if err := json.NewDecoder(f).Decode(&creds); err != nil {
+		return ""
+	}
+	return creds.Credentials[hostname].Token
+}
+
+func readTFE(ctx context.Context, address string, organization string, ws string, token string) (io.ReadCloser, error) {
+	if token == "" {
+		token = os.Getenv("TFE_TOKEN")
+	}
+	if token == "" {
+		if u, err := url.Parse(address); err == nil {
+			token = tfeCredentialsToken(u.Host)
+		}
 	}
 
 	var err error
@@ -53,12 +111,12 @@ func readTFE(ctx context.Context, hostname string, organization string, ws strin
 
 	workspace, err := client.Workspaces.Read(ctx, organization, ws)
 	if err != nil {
-		return nil, err
+		return nil, errors.Wrapf(err, "failed to read workspace %s/%s", organization, ws)
 	}
 	state, err := client.StateVersions.ReadCurrent(ctx, workspace.ID)
 	if err != nil {
-		return nil, err
+		return nil, errors.Wrapf(err, "failed to read current state version of %s/%s", organization, ws)
 	}
 
-	return readHTTP(ctx, state.DownloadURL)
+	return readHTTP(ctx, state.DownloadURL, httpOption{})
 }
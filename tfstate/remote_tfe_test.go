@@ -0,0 +1,81 @@
+package tfstate_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fujiwara/tfstate-lookup/tfstate"
+)
+
+func newTFEServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	ts := httptest.NewServer(mux)
+	mux.HandleFunc("/api/v2/ping", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("/api/v2/organizations/myorg/workspaces/app-dev", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer mytoken" {
+			http.Error(w, `{"errors":[{"status":"401","title":"unauthorized"}]}`, http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/vnd.api+json")
+		fmt.Fprint(w, `{"data":{"id":"ws-abcdefg","type":"workspaces","attributes":{"name":"app-dev"}}}`)
+	})
+	mux.HandleFunc("/api/v2/workspaces/ws-abcdefg/current-state-version", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/vnd.api+json")
+		fmt.Fprintf(w, `{"data":{"id":"sv-abcdefg","type":"state-versions","attributes":{"hosted-state-download-url":"%s/download"}}}`, ts.URL)
+	})
+	mux.HandleFunc("/download", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, "test/terraform.tfstate")
+	})
+	return ts
+}
+
+func TestReadTFE(t *testing.T) {
+	ts := newTFEServer(t)
+	defer ts.Close()
+
+	t.Setenv("TFE_TOKEN", "mytoken")
+	src, err := tfstate.ReadTFE(context.Background(), ts.URL, "myorg", "app-dev", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	state, err := tfstate.Read(context.Background(), src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testLookupState(t, state)
+
+	if _, err := tfstate.ReadTFE(context.Background(), ts.URL, "myorg", "app-dev", "invalid"); err == nil {
+		t.Error("must be failed with an invalid token")
+	}
+}
+
+func TestReadTFECredentialsFile(t *testing.T) {
+	ts := newTFEServer(t)
+	defer ts.Close()
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("TFE_TOKEN", "")
+	if err := os.Mkdir(filepath.Join(home, ".terraform.d"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	creds := fmt.Sprintf(`{"credentials":{"%s":{"token":"mytoken"}}}`, strings.TrimPrefix(ts.URL, "http://"))
+	if err := os.WriteFile(filepath.Join(home, ".terraform.d", "credentials.tfrc.json"), []byte(creds), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := tfstate.ReadTFE(context.Background(), ts.URL, "myorg", "app-dev", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src.Close()
+}
@@ -0,0 +1,68 @@
+package tfstate
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+// Resource represents a resource in tfstate.
+type Resource struct {
+	// Address is the resource address without an index key, such as module.foo.aws_instance.bar
+	Address   string
+	Module    string
+	Mode      string
+	Type      string
+	Name      string
+	Provider  string
+	Instances []ResourceInstance
+}
+
+// ResourceInstance represents an instance of a resource in tfstate.
+type ResourceInstance struct {
+	// Address is the resource instance address, such as module.foo.aws_instance.bar["a"]
+	Address string
+	// IndexKey is the decoded index key (string or json.Number), nil for a resource without count or for_each.
+	IndexKey interface{}
+	// Deposed is the deposed key, empty for the current object.
+	Deposed    string
+	Attributes Object
+}
+
+// Resources returns resources in tfstate in the order of the state.
+func (s *TFState) Resources() ([]Resource, error) {
+	resources := make([]Resource, 0, len(s.state.Resources))
+	for _, r := range s.state.Resources {
+		res := Resource{
+			Address:   r.address(nil),
+			Module:    r.Module,
+			Mode:      r.Mode,
+			Type:      r.Type,
+			Name:      r.Name,
+			Provider:  r.Provider,
+			Instances: make([]ResourceInstance, 0, len(r.Instances)),
+		}
+		for _, i := range r.Instances {
+			ins := ResourceInstance{
+				Address:    r.address(i.IndexKey),
+				Deposed:    i.Deposed,
+				Attributes: Object{noneNil(i.Attributes, i.AttributesFlat)},
+			}
+			if len(i.IndexKey) > 0 {
+				if err := decodeIndexKey(i.IndexKey, &ins.IndexKey); err != nil {
+					return nil, errors.Wrapf(err, "invalid index key of %s", res.Address)
+				}
+			}
+			res.Instances = append(res.Instances, ins)
+		}
+		resources = append(resources, res)
+	}
+	return resources, nil
+}
+
+func decodeIndexKey(raw json.RawMessage, v *interface{}) error {
+	d := json.NewDecoder(bytes.NewReader(raw))
+	d.UseNumber()
+	return d.Decode(v)
+}
@@ -0,0 +1,58 @@
+package tfstate_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/fujiwara/tfstate-lookup/tfstate"
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestResources(t *testing.T) {
+	state, err := tfstate.ReadFile(context.Background(), "test/addresses.tfstate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resources, err := state.Resources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := `provider["registry.terraform.io/hashicorp/aws"]`
+	expected := map[string]tfstate.Resource{
+		"aws_instance.count": {
+			Address: "aws_instance.count", Mode: "managed", Type: "aws_instance", Name: "count", Provider: provider,
+			Instances: []tfstate.ResourceInstance{
+				{Address: "aws_instance.count[0]", IndexKey: json.Number("0"), Attributes: tfstate.Object{Value: map[string]interface{}{"id": "i-count0"}}},
+				{Address: "aws_instance.count[1]", IndexKey: json.Number("1"), Attributes: tfstate.Object{Value: map[string]interface{}{"id": "i-count1"}}},
+			},
+		},
+		"module.network.data.aws_subnet.selected": {
+			Address: "module.network.data.aws_subnet.selected", Module: "module.network", Mode: "data", Type: "aws_subnet", Name: "selected", Provider: provider,
+			Instances: []tfstate.ResourceInstance{
+				{Address: "module.network.data.aws_subnet.selected", Attributes: tfstate.Object{Value: map[string]interface{}{"id": "subnet-selected", "cidr_block": "10.0.1.0/24"}}},
+			},
+		},
+		"aws_instance.replaced": {
+			Address: "aws_instance.replaced", Mode: "managed", Type: "aws_instance", Name: "replaced", Provider: provider,
+			Instances: []tfstate.ResourceInstance{
+				{Address: "aws_instance.replaced", Attributes: tfstate.Object{Value: map[string]interface{}{"id": "i-new"}}},
+				{Address: "aws_instance.replaced", Deposed: "deadbeef", Attributes: tfstate.Object{Value: map[string]interface{}{"id": "i-old", "password": "secret"}}},
+			},
+		},
+	}
+	found := 0
+	for _, r := range resources {
+		e, ok := expected[r.Address]
+		if !ok {
+			continue
+		}
+		found++
+		if diff := cmp.Diff(r, e); diff != "" {
+			t.Errorf("unexpected resource %s %s", r.Address, diff)
+		}
+	}
+	if found != len(expected) {
+		t.Errorf("expected resources are not found %d/%d", found, len(expected))
+	}
+}
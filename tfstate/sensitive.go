@@ -0,0 +1,128 @@
+package tfstate
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// sensitivePath is a path to a sensitive attribute in sensitive_attributes of the instance.
+// e.g. [{"type":"get_attr","value":"tags"},{"type":"index","value":{"value":"secret","type":"string"}}]
+type sensitivePath []sensitiveStep
+
+type sensitiveStep struct {
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value"`
+}
+
+// key returns the attribute name for get_attr, or the key (string or number) for index.
+func (st sensitiveStep) key() (interface{}, bool) {
+	switch st.Type {
+	case "get_attr":
+		var name string
+		if err := json.Unmarshal(st.Value, &name); err != nil {
+			return nil, false
+		}
+		return name, true
+	case "index":
+		var index struct {
+			Value interface{} `json:"value"`
+		}
+		if err := json.Unmarshal(st.Value, &index); err != nil {
+			return nil, false
+		}
+		return index.Value, true
+	}
+	return nil, false
+}
+
+// String returns the path in the form of Paths such as .tags.env and .list[0].
+func (p sensitivePath) String() string {
+	var b strings.Builder
+	for _, st := range p {
+		key, ok := st.key()
+		if !ok {
+			break
+		}
+		switch k := key.(type) {
+		case string:
+			if flatKey.MatchString(k) {
+				b.WriteString("." + k)
+			} else {
+				b.WriteString("[" + strconv.Quote(k) + "]")
+			}
+		case float64:
+			b.WriteString("[" + strconv.FormatFloat(k, 'f', -1, 64) + "]")
+		}
+	}
+	return b.String()
+}
+
+// SensitiveAttributes returns paths of sensitive attributes of the address such as [".password", ".tags.secret"].
+// A sensitive output returns ["."] as the whole value is sensitive.
+func (s *TFState) SensitiveAttributes(address string) ([]string, error) {
+	s.once.Do(s.scan)
+	address = canonicalIndexKeys(strings.TrimSpace(address))
+	ins, ok := s.scanned[address]
+	if !ok {
+		return nil, s.notFound(address)
+	}
+	if ins.sensitive {
+		return []string{"."}, nil
+	}
+	paths := make([]string, 0, len(ins.SensitiveAttributes))
+	for _, p := range ins.SensitiveAttributes {
+		paths = append(paths, p.String())
+	}
+	return paths, nil
+}
+
+// maskSensitive returns a copy of v which values at the paths are replaced by the mask.
+// v is not modified.
+func maskSensitive(v interface{}, paths []sensitivePath, mask interface{}) interface{} {
+	for _, p := range paths {
+		v = maskPath(v, p, mask)
+	}
+	return v
+}
+
+func maskPath(v interface{}, p sensitivePath, mask interface{}) interface{} {
+	if len(p) == 0 {
+		if v == nil {
+			return nil
+		}
+		return mask
+	}
+	key, ok := p[0].key()
+	if !ok {
+		return v
+	}
+	switch vv := v.(type) {
+	case map[string]interface{}:
+		k, ok := key.(string)
+		if !ok {
+			return v
+		}
+		e, ok := vv[k]
+		if !ok {
+			return v
+		}
+		m := make(map[string]interface{}, len(vv))
+		for kk, ee := range vv {
+			m[kk] = ee
+		}
+		m[k] = maskPath(e, p[1:], mask)
+		return m
+	case []interface{}:
+		f, ok := key.(float64)
+		i := int(f)
+		if !ok || float64(i) != f || i < 0 || i >= len(vv) {
+			return v
+		}
+		l := make([]interface{}, len(vv))
+		copy(l, vv)
+		l[i] = maskPath(vv[i], p[1:], mask)
+		return l
+	}
+	return v
+}
@@ -0,0 +1,125 @@
+package tfstate
+
+import (
+	"strings"
+	"sync"
+)
+
+// NotFoundError is returned by Lookup when the key is not found in the state.
+type NotFoundError struct {
+	Key string
+
+	state      *TFState
+	once       sync.Once
+	suggestion string
+}
+
+func (e *NotFoundError) Error() string {
+	if s := e.Suggestion(); s != "" {
+		return e.Key + " is " + ErrNotFound.Error() + ", did you mean " + s + "?"
+	}
+	return e.Key + " is " + ErrNotFound.Error()
+}
+
+// Unwrap makes errors.Is(err, ErrNotFound) true.
+func (e *NotFoundError) Unwrap() error {
+	return ErrNotFound
+}
+
+// Suggestion returns a similar key found in the state, or "" if nothing is found.
+// It is computed on the first call.
+func (e *NotFoundError) Suggestion() string {
+	e.once.Do(func() {
+		if e.state != nil {
+			e.suggestion = suggest(e.Key, e.state.suggestNames(e.Key))
+		}
+	})
+	return e.suggestion
+}
+
+// notFound returns NotFoundError of the key which suggests a similar key from the scanned names.
+func (s *TFState) notFound(key string) *NotFoundError {
+	return &NotFoundError{Key: key, state: s}
+}
+
+// suggestNames returns the scanned names, and the names with top-level attributes of the names which the key starts with.
+func (s *TFState) suggestNames(key string) []string {
+	names := make([]string, 0, len(s.scanned))
+	for name, ins := range s.scanned {
+		names = append(names, name)
+		if rest := strings.TrimPrefix(key, name); rest == key || rest == "" || (rest[0] != '.' && rest[0] != '[') {
+			continue
+		}
+		if attrs, ok := noneNil(ins.data, ins.Attributes, ins.AttributesFlat).(map[string]interface{}); ok {
+			for attr := range attrs {
+				if flatKey.MatchString(attr) {
+					names = append(names, name+"."+attr)
+				}
+			}
+		}
+	}
+	return names
+}
+
+// suggest returns the name closest to the head of the key by Levenshtein distance, with the rest of the key (attributes) appended.
+// Names at a distance over a third of their length are not suggested.
+func suggest(key string, names []string) string {
+	var suggestion string
+	best := -1
+	for _, name := range names {
+		limit := len(name) / 3
+		// the head of the key may be longer or shorter than the name by the distance
+		for l := len(name) - limit; l <= len(name)+limit; l++ {
+			if l < 0 || l > len(key) {
+				continue
+			}
+			if rest := key[l:]; rest != "" && rest[0] != '.' && rest[0] != '[' {
+				continue
+			}
+			d := levenshtein(key[:l], name)
+			if d > limit {
+				continue
+			}
+			s := name + key[l:]
+			if s == key {
+				// the name itself matches, but the rest of the key does not
+				continue
+			}
+			if best < 0 || d < best || (d == best && s < suggestion) {
+				best = d
+				suggestion = s
+			}
+		}
+	}
+	return suggestion
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+func minInt(v int, vs ...int) int {
+	for _, x := range vs {
+		if x < v {
+			v = x
+		}
+	}
+	return v
+}